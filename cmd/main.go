@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
@@ -20,10 +21,12 @@ import (
 	pb "github.com/GalitskyKK/nekkus-core/pkg/protocol"
 	"github.com/GalitskyKK/nekkus-hub/assets"
 	"github.com/GalitskyKK/nekkus-hub/internal/hubgrpc"
+	"github.com/GalitskyKK/nekkus-hub/internal/jobs"
 	"github.com/GalitskyKK/nekkus-hub/internal/pathutil"
 	"github.com/GalitskyKK/nekkus-hub/internal/process"
 	"github.com/GalitskyKK/nekkus-hub/internal/registry"
 	"github.com/GalitskyKK/nekkus-hub/internal/server"
+	"github.com/GalitskyKK/nekkus-hub/internal/settings"
 	"github.com/GalitskyKK/nekkus-hub/internal/api"
 	"github.com/GalitskyKK/nekkus-hub/ui"
 	"google.golang.org/grpc"
@@ -33,8 +36,11 @@ var (
 	httpPort  = flag.Int("port", 9000, "HTTP port")
 	grpcPort  = flag.Int("grpc-port", 19000, "gRPC port")
 	modulesDirFlag = flag.String("modules-dir", "", "Modules directory (default: next to executable)")
+	dataDirFlag    = flag.String("data-dir", "", "Hub data directory for settings and state (default: user config dir/nekkus/hub)")
 	headless  = flag.Bool("headless", false, "Run without GUI")
 	trayOnly  = flag.Bool("tray-only", false, "Start minimized to tray")
+	detachModules   = flag.Bool("detach-modules", false, "Leave running modules alive when the hub exits")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 20*time.Second, "How long to wait for modules to stop on exit")
 )
 
 func waitForServer(host string, port int, timeout time.Duration) {
@@ -64,11 +70,55 @@ func main() {
 		log.Fatalf("create modules dir: %v", err)
 	}
 
+	dataDir, err := pathutil.ResolveHubDataDir(*dataDirFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store, err := settings.Load(dataDir)
+	if err != nil {
+		log.Printf("settings: %v", err)
+	}
+
 	reg := registry.New()
-	if err := reg.ScanModules(modulesDir); err != nil {
+	if _, err := reg.ScanModules(modulesDir); err != nil {
 		log.Printf("module scan: %v", err)
 	}
-	procMgr := process.NewManager()
+	procMgr := process.NewManager(reg, dataDir)
+	// Скан не убирает из реестра модуль, который ещё работает.
+	reg.SetInUse(procMgr.HasProcesses)
+	// Когда такой модуль останавливается, реестр его убирает.
+	procMgr.SetOnIdle(reg.Release)
+	// Модули, скопированные в каталог или удалённые из него, подхватываются без Rescan.
+	go reg.Watch(ctx, modulesDir)
+	if mode := store.EnvMode(); mode != "" {
+		if err := procMgr.SetEnvMode(mode); err != nil {
+			log.Printf("settings: %v", err)
+		}
+	}
+	for id, limits := range store.ResourceLimits() {
+		if err := procMgr.SetResourceLimits(id, limits); err != nil {
+			log.Printf("resource limits of %s: %v", id, err)
+		}
+	}
+	for id, policy := range store.RestartPolicies() {
+		if err := procMgr.SetRestartPolicy(id, policy); err != nil {
+			log.Printf("restart policy of %s: %v", id, err)
+		}
+	}
+	for id, overrides := range store.LaunchOverrides() {
+		if err := procMgr.SetLaunchOverrides(id, overrides); err != nil {
+			log.Printf("launch overrides of %s: %v", id, err)
+		}
+	}
+	for id, instances := range store.Instances() {
+		for name, overrides := range instances {
+			if err := procMgr.SetInstanceOverrides(id, name, overrides); err != nil {
+				log.Printf("instance %s of %s: %v", name, id, err)
+			}
+		}
+	}
+	// Модули, оставленные работать прошлым запуском (--detach-modules), берём под управление.
+	procMgr.AdoptProcesses()
 
 	uiFS, err := fs.Sub(ui.Assets, "frontend/dist")
 	if err != nil {
@@ -80,6 +130,8 @@ func main() {
 	server.RegisterRoutes(srv, api.ServerConfig{
 		Registry:       reg,
 		ProcessManager: procMgr,
+		Settings:       store,
+		Jobs:           jobs.NewManager(ctx),
 		ModulesDir:     modulesDir,
 		GRPCAddr:       grpcAddr,
 	})
@@ -92,7 +144,7 @@ func main() {
 
 	go func() {
 		if err := srv.StartGRPC(func(s *grpc.Server) {
-			pb.RegisterNekkusHubServer(s, hubgrpc.NewServer(reg))
+			pb.RegisterNekkusHubServer(s, hubgrpc.NewServer(reg, procMgr))
 		}); err != nil {
 			log.Printf("gRPC server: %v", err)
 		}
@@ -112,20 +164,72 @@ func main() {
 
 	log.Printf("nekkus HUB → http://localhost:%d", *httpPort)
 
+	go func() {
+		// Модули регистрируются в Hub по gRPC, поэтому стартуем их после того, как он слушает.
+		waitForServer("127.0.0.1", *grpcPort, 5*time.Second)
+		for _, mod := range store.LaunchSet(reg.ListModules()) {
+			if err := procMgr.StartModule(ctx, mod, modulesDir, grpcAddr, false, true); err != nil {
+				log.Printf("autostart %s: %v", mod.ID, err)
+			}
+		}
+		// Именованные экземпляры из прошлой сессии; экземпляры по умолчанию уже в LaunchSet.
+		if store.RestoreSession() {
+			for _, key := range store.LastSession() {
+				id, instance := process.SplitInstanceKey(key)
+				if instance == "" {
+					continue
+				}
+				mod, ok := reg.GetManifest(id)
+				if !ok {
+					log.Printf("restore %s: module not found", key)
+					continue
+				}
+				if err := procMgr.StartInstance(ctx, mod, instance, modulesDir, grpcAddr, false, true); err != nil {
+					log.Printf("restore %s: %v", key, err)
+				}
+			}
+		}
+	}()
+
+	var shutdownOnce sync.Once
+	stopModules := func() {
+		shutdownOnce.Do(func() {
+			if err := store.SetLastSession(procMgr.RunningModules()); err != nil {
+				log.Printf("save session: %v", err)
+			}
+			if *detachModules {
+				log.Printf("leaving modules running (--detach-modules)")
+				procMgr.FlushState()
+				return
+			}
+			shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), *shutdownTimeout)
+			defer cancelShutdown()
+			if err := procMgr.Shutdown(shutdownCtx); err != nil {
+				log.Printf("module shutdown: %v", err)
+			}
+		})
+	}
+
 	if *headless {
 		sig := make(chan os.Signal, 1)
 		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
 		<-sig
+		// Сначала отменяем автозапуск и задачи, иначе начатые запуски переживут остановку.
 		cancel()
+		stopModules()
 		return
 	}
 
 	waitForServer("127.0.0.1", *httpPort, 5*time.Second)
 
 	rescan := func() {
-		if err := reg.ScanModules(modulesDir); err != nil {
+		result, err := reg.ScanModules(modulesDir)
+		if err != nil {
 			log.Printf("rescan: %v", err)
 		}
+		for _, id := range result.Kept {
+			log.Printf("rescan: %s is running, keeping it until it stops", id)
+		}
 	}
 
 	desktop.Launch(desktop.AppConfig{
@@ -139,7 +243,9 @@ func main() {
 			{Label: "Rescan", OnClick: rescan},
 		},
 		OnQuit: func() {
+			// desktop вызывает os.Exit сразу после OnQuit, поэтому останавливаем модули синхронно.
 			cancel()
+			stopModules()
 		},
 	})
 }
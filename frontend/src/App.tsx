@@ -10,13 +10,15 @@ import {
 } from "@nekkus/ui-kit";
 import {
   addModule,
+  fetchScanReport,
   fetchSummary,
   openModuleUI,
   rescanModules,
   startModule,
   stopModule,
+  subscribeModuleEvents,
 } from "./api";
-import type { ModuleSummary } from "./types";
+import type { ModuleSummary, ScanDiagnostic } from "./types";
 
 /** Payload от Net /api/status для виджета в Hub */
 type NetStatusPayload = {
@@ -45,6 +47,7 @@ function isNetPayload(payload: unknown): payload is NetStatusPayload {
 
 function App() {
   const [modules, setModules] = useState<ModuleSummary[]>([]);
+  const [diagnostics, setDiagnostics] = useState<ScanDiagnostic[]>([]);
   const [errorMessage, setErrorMessage] = useState<string | null>(null);
   const [isBusy, setIsBusy] = useState(false);
   const addModuleInputRef = useRef<HTMLInputElement>(null);
@@ -58,8 +61,12 @@ function App() {
   const loadSummary = useCallback(async () => {
     try {
       setErrorMessage(null);
-      const summary = await fetchSummary();
+      const [summary, report] = await Promise.all([
+        fetchSummary(),
+        fetchScanReport(),
+      ]);
       setModules(summary);
+      setDiagnostics(report.diagnostics);
     } catch (error) {
       setErrorMessage(
         error instanceof Error ? error.message : "Failed to load modules",
@@ -71,6 +78,11 @@ function App() {
     void loadSummary();
   }, [loadSummary]);
 
+  useEffect(
+    () => subscribeModuleEvents(() => void loadSummary()),
+    [loadSummary],
+  );
+
   useEffect(() => {
     if (isBusy) return;
     const intervalId = window.setInterval(() => void loadSummary(), 3000);
@@ -249,6 +261,20 @@ function App() {
           </div>
         ) : null}
 
+        {diagnostics.length > 0 ? (
+          <Section title="Не загружены" className="hub__diagnostics">
+            <ul className="hub__diagnostics-list">
+              {diagnostics.map((d) => (
+                <li key={`${d.dir}:${d.kind}`} className="hub__card-error">
+                  <strong>{d.dir}</strong>
+                  {d.line ? ` (${d.path.split(/[\\/]/).pop()}:${d.line}:${d.column})` : ""}
+                  {d.field ? ` [${d.field}]` : ""}: {d.message}
+                </li>
+              ))}
+            </ul>
+          </Section>
+        ) : null}
+
         <Section title="" className="hub__grid-wrap">
           <div className="hub__grid">
             {modules.map((module) => (
@@ -1,4 +1,4 @@
-import type { ModuleSummary } from "./types"
+import type { Job, ModuleEvent, ModuleSummary, ScanReport, ScanResult, StopResult } from "./types"
 
 const apiBase = import.meta.env.VITE_API_BASE ?? ""
 
@@ -21,20 +21,60 @@ async function request<T>(path: string, init?: RequestInit): Promise<T> {
 
 export const fetchSummary = () => request<ModuleSummary[]>("/api/summary")
 export const rescanModules = () =>
-  request<ModuleSummary[]>("/api/scan", {
+  request<ScanResult>("/api/scan", {
     method: "POST"
   })
-export const startModule = (id: string) =>
-  request<{ ok: boolean }>(`/api/modules/${encodeURIComponent(id)}/start`, {
-    method: "POST"
-  })
-export const openModuleUI = (id: string) =>
-  request<{ ok: boolean }>(`/api/modules/${encodeURIComponent(id)}/open-ui`, {
+export const fetchScanReport = () => request<ScanReport>("/api/scan/report")
+
+/** Subscribes to added/updated/removed modules; returns a function that unsubscribes. */
+export function subscribeModuleEvents(onEvent: (event: ModuleEvent) => void): () => void {
+  const source = new EventSource(`${apiBase}/api/modules/events`)
+  const handler = (message: MessageEvent<string>) => onEvent(JSON.parse(message.data) as ModuleEvent)
+  for (const type of ["added", "updated", "removed"]) {
+    source.addEventListener(type, handler)
+  }
+  return () => source.close()
+}
+
+const jobPollInterval = 300
+
+/** Polls a job until it finishes; throws with the job error if it did not succeed. */
+export async function waitForJob(job: Job): Promise<Job> {
+  let current = job
+  while (current.state === "running") {
+    await new Promise((resolve) => setTimeout(resolve, jobPollInterval))
+    current = await request<Job>(`/api/jobs/${encodeURIComponent(current.id)}`)
+  }
+  if (current.state !== "succeeded") {
+    throw new Error(current.error || `Job ${current.kind} ${current.state}`)
+  }
+  return current
+}
+
+export const cancelJob = (id: string) =>
+  request<Job>(`/api/jobs/${encodeURIComponent(id)}/cancel`, {
     method: "POST"
   })
-export const stopModule = (id: string) =>
-  request<{ ok: boolean }>(`/api/modules/${encodeURIComponent(id)}/stop`, {
-    method: "POST"
+
+const moduleJob = async (id: string, action: string) => {
+  const { job } = await request<{ ok: boolean; job: Job }>(
+    `/api/modules/${encodeURIComponent(id)}/${action}`,
+    { method: "POST" }
+  )
+  return waitForJob(job)
+}
+
+export const startModule = (id: string) => moduleJob(id, "start")
+export const openModuleUI = (id: string) => moduleJob(id, "open-ui")
+export const stopModule = async (id: string) => {
+  const job = await moduleJob(id, "stop")
+  return { ok: true, result: job.result as StopResult }
+}
+
+export const setAutostart = (id: string, autostart: boolean | null) =>
+  request<{ ok: boolean; autostart: boolean }>(`/api/modules/${encodeURIComponent(id)}/autostart`, {
+    method: "POST",
+    body: JSON.stringify({ autostart })
   })
 
 /** Add module from folder: FormData keys = relative paths (e.g. manifest.json, nekkus-net.exe). */
@@ -49,5 +89,7 @@ export async function addModule(formData: FormData): Promise<{ ok: string; modul
     const text = await response.text()
     throw new Error(text || `Add module failed: ${response.status}`)
   }
-  return response.json() as Promise<{ ok: string; module_id: string }>
+  const body = (await response.json()) as { ok: string; module_id: string; job: Job }
+  await waitForJob(body.job)
+  return body
 }
@@ -6,13 +6,83 @@ export type WidgetConfig = {
   supports_resize?: boolean
 }
 
+export type Dependency = {
+  id: string
+  version?: string
+}
+
 export type ModuleManifest = {
+  manifest_version?: number
   id: string
   name?: string
   description?: string
   version?: string
   grpc_addr?: string
   widget?: WidgetConfig
+  depends_on?: (string | Dependency)[]
+}
+
+export type RestartPolicy = {
+  mode?: "never" | "on-failure" | "always"
+  max_restarts?: number
+  window?: string
+  backoff_initial?: string
+  backoff_max?: string
+}
+
+export type ExitInfo = {
+  code: number
+  signal?: string
+  error?: string
+  unknown?: boolean
+  exited_at: string
+}
+
+export type StopResult = {
+  outcome: "clean" | "forced" | "not-running"
+  duration_ms: number
+  exit?: ExitInfo
+  running_dependents?: string[]
+}
+
+export type SupervisorStatus = {
+  policy: RestartPolicy
+  overridden: boolean
+  restarts: number
+  crash_looping: boolean
+  last_exit?: ExitInfo
+}
+
+export type ModuleState =
+  | "stopped"
+  | "starting"
+  | "ready"
+  | "unhealthy"
+  | "stopping"
+  | "crashed"
+  | "crash-looping"
+
+export type Transition = {
+  from: ModuleState
+  to: ModuleState
+  at: string
+  error?: string
+}
+
+export type ModuleStatus = {
+  state: ModuleState
+  since: string
+  last_error?: string
+  transitions?: Transition[]
+}
+
+export type HealthStatus = {
+  probe?: "grpc-health" | "get-info"
+  healthy: boolean
+  consecutive_failures: number
+  last_check?: string
+  last_success?: string
+  last_error?: string
 }
 
 export type ModuleSummary = {
@@ -21,4 +91,141 @@ export type ModuleSummary = {
   payload?: unknown
   error?: string
   running?: boolean
+  status?: ModuleStatus
+  health?: HealthStatus
+  metrics?: MetricsSample
+  supervisor?: SupervisorStatus
+  last_crash?: { reason: string; exited_at: string }
+  autostart?: boolean
+  addr?: string
+  data_dir?: string
+  data_dir_error?: string
+  instances?: InstanceSummary[]
+}
+
+export type InstanceSummary = {
+  instance: string
+  defined: boolean
+  running: boolean
+  addr?: string
+  status: ModuleStatus
+  overrides: LaunchOverrides
+  last_crash?: { reason: string; exited_at: string }
+  supervisor?: SupervisorStatus
+  limits?: LimitsStatus
+}
+
+export type LogLine = {
+  seq: number
+  time: string
+  stream: "stdout" | "stderr"
+  text: string
+}
+
+export type LaunchOverrides = {
+  args?: string[]
+  env?: Record<string, string>
+  log_level?: string
+}
+
+export type EnvMode = "inherit" | "isolated"
+
+export type ResourceLimits = {
+  max_memory?: string
+  cpu_weight?: number
+  cpu_quota?: string
+  max_open_files?: number
+  max_processes?: number
+}
+
+export type EnforcedLimit = {
+  limit: string
+  value: string
+  enforced: boolean
+  mechanism?: "cgroup" | "rlimit"
+  reason?: string
+}
+
+export type LimitsStatus = {
+  effective: ResourceLimits
+  overridden: boolean
+  enforced?: EnforcedLimit[]
+  manifest?: ResourceLimits
+}
+
+export type MetricsSample = {
+  time: string
+  cpu_percent: number
+  rss_bytes: number
+  threads: number
+  open_fds: number
+  read_bytes: number
+  write_bytes: number
+  processes: number
+}
+
+export type CrashReport = ExitInfo & {
+  reason: string
+  started_at: string
+  runtime_seconds: number
+  stderr_tail?: string[]
+}
+
+export type Job = {
+  id: string
+  kind: "start" | "open-ui" | "stop" | "stop-cascade" | "install"
+  module_id?: string
+  state: "running" | "succeeded" | "failed" | "cancelled"
+  created_at: string
+  finished_at?: string
+  result?: unknown
+  error?: string
+}
+
+export type ModuleEvent = {
+  type: "added" | "updated" | "removed"
+  module_id: string
+  version?: string
+  time: string
+}
+
+export type ScanResult = {
+  added: string[]
+  updated: string[]
+  removed: string[]
+  unchanged: string[]
+  kept?: string[]
+  rejected?: Record<string, string>
+}
+
+export type ScanDiagnostic = {
+  dir: string
+  path: string
+  kind:
+    | "missing-manifest"
+    | "unreadable"
+    | "syntax"
+    | "type"
+    | "unknown-field"
+    | "missing-id"
+    | "local-override"
+    | "invalid"
+    | "unsupported-version"
+    | "duplicate-id"
+    | "dependency"
+    | "migrated"
+  module_id?: string
+  field?: string
+  fields?: { field: string; message: string }[]
+  line?: number
+  column?: number
+  message: string
+}
+
+export type ScanReport = {
+  modules_dir: string
+  time: string
+  modules: number
+  diagnostics: ScanDiagnostic[]
+  warnings: ScanDiagnostic[]
 }
@@ -1,18 +1,23 @@
 package api
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"strings"
 
+	"github.com/GalitskyKK/nekkus-hub/internal/jobs"
 	"github.com/GalitskyKK/nekkus-hub/internal/process"
 	"github.com/GalitskyKK/nekkus-hub/internal/registry"
+	"github.com/GalitskyKK/nekkus-hub/internal/settings"
 )
 
 // ServerConfig holds dependencies for HTTP handlers.
 type ServerConfig struct {
 	Registry       *registry.Registry
 	ProcessManager *process.Manager
+	Settings       *settings.Store
+	Jobs           *jobs.Manager
 	ModulesDir     string
 	GRPCAddr       string
 }
@@ -40,7 +45,7 @@ func NewMux(cfg ServerConfig) *http.ServeMux {
 			WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 			return
 		}
-		summaries := BuildModuleSummaries(cfg.Registry.ListModules(), cfg.ProcessManager)
+		summaries := BuildModuleSummaries(cfg.Registry.ListModules(), cfg.ProcessManager, cfg.Settings, cfg.ModulesDir)
 		WriteJSON(w, http.StatusOK, summaries)
 	})
 
@@ -52,11 +57,12 @@ func NewMux(cfg ServerConfig) *http.ServeMux {
 			WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 			return
 		}
-		if err := cfg.Registry.ScanModules(cfg.ModulesDir); err != nil {
+		result, err := cfg.Registry.ScanModules(cfg.ModulesDir)
+		if err != nil && len(result.Rejected) == 0 {
 			WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
 		}
-		WriteJSON(w, http.StatusOK, cfg.Registry.ListModules())
+		WriteJSON(w, http.StatusOK, result)
 	})
 
 	mux.HandleFunc("/api/modules/add", func(w http.ResponseWriter, r *http.Request) {
@@ -72,7 +78,7 @@ func NewMux(cfg ServerConfig) *http.ServeMux {
 			WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 			return
 		}
-		if err := cfg.Registry.ScanModules(cfg.ModulesDir); err != nil {
+		if _, err := cfg.Registry.ScanModules(cfg.ModulesDir); err != nil {
 			log.Printf("rescan after add: %v", err)
 		}
 		WriteJSON(w, http.StatusOK, map[string]string{"ok": "true", "module_id": moduleID})
@@ -104,21 +110,23 @@ func NewMux(cfg ServerConfig) *http.ServeMux {
 
 		switch action {
 		case "start":
-			if err := cfg.ProcessManager.StartModule(modManifest, cfg.ModulesDir, cfg.GRPCAddr, false, true); err != nil {
+			if err := cfg.ProcessManager.StartModule(context.Background(), modManifest, cfg.ModulesDir, cfg.GRPCAddr, false, true); err != nil {
 				WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 				return
 			}
 		case "open-ui":
-			_ = cfg.ProcessManager.StopModule(modManifest)
-			if err := cfg.ProcessManager.StartModule(modManifest, cfg.ModulesDir, cfg.GRPCAddr, true, false); err != nil {
+			if err := cfg.ProcessManager.RestartModule(context.Background(), modManifest, cfg.ModulesDir, cfg.GRPCAddr, true, false); err != nil {
 				WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 				return
 			}
 		case "stop":
-			if err := cfg.ProcessManager.StopModule(modManifest); err != nil {
+			result, err := cfg.ProcessManager.StopModule(context.Background(), modManifest)
+			if err != nil {
 				WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 				return
 			}
+			WriteJSON(w, http.StatusOK, map[string]interface{}{"ok": true, "result": result})
+			return
 		default:
 			WriteJSON(w, http.StatusNotFound, map[string]string{"error": "unknown action"})
 			return
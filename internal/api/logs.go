@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/process"
+)
+
+const defaultLogTail = 200
+
+// ParseLogQuery читает tail, since (seq или RFC3339) и stream из query string.
+func ParseLogQuery(r *http.Request) (process.LogQuery, error) {
+	values := r.URL.Query()
+	q := process.LogQuery{Tail: defaultLogTail}
+
+	if tail := values.Get("tail"); tail != "" {
+		n, err := strconv.Atoi(tail)
+		if err != nil || n < 0 {
+			return q, fmt.Errorf("invalid tail %q", tail)
+		}
+		q.Tail = n
+	}
+
+	if since := values.Get("since"); since != "" {
+		if seq, err := strconv.ParseUint(since, 10, 64); err == nil {
+			q.SinceSeq = seq
+		} else if t, err := time.Parse(time.RFC3339Nano, since); err == nil {
+			q.Since = t
+		} else {
+			return q, fmt.Errorf("invalid since %q: expected sequence number or RFC3339 time", since)
+		}
+	}
+
+	switch stream := values.Get("stream"); stream {
+	case "", process.StreamStdout, process.StreamStderr:
+		q.Stream = stream
+	default:
+		return q, fmt.Errorf("invalid stream %q", stream)
+	}
+	return q, nil
+}
+
+// StreamLogs отдаёт backlog и новые строки лога модуля как Server-Sent Events.
+// Last-Event-ID позволяет клиенту продолжить с места обрыва.
+func StreamLogs(w http.ResponseWriter, r *http.Request, manager *process.Manager, moduleID string) {
+	q, err := ParseLogQuery(r)
+	if err != nil {
+		WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		if seq, parseErr := strconv.ParseUint(lastID, 10, 64); parseErr == nil {
+			q.SinceSeq = seq
+			q.Tail = 0
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming is not supported"})
+		return
+	}
+
+	backlog, lines, cancel := manager.SubscribeLogs(moduleID, q)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, line := range backlog {
+		if writeLogEvent(w, line) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case line := <-lines:
+			if writeLogEvent(w, line) != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeLogEvent(w http.ResponseWriter, line process.LogLine) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", line.Seq, data)
+	return err
+}
@@ -2,7 +2,10 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/process"
 )
 
 // WriteJSON sets Content-Type and status, then encodes payload as JSON.
@@ -15,7 +18,7 @@ func WriteJSON(w http.ResponseWriter, status int, payload interface{}) {
 // ApplyCORS sets CORS headers; returns true if request was OPTIONS (caller should return).
 func ApplyCORS(w http.ResponseWriter, r *http.Request) bool {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
 	if r.Method == http.MethodOptions {
@@ -25,3 +28,12 @@ func ApplyCORS(w http.ResponseWriter, r *http.Request) bool {
 
 	return false
 }
+
+// WriteOperationError отвечает 409 на недопустимый переход жизненного цикла и 400 на прочие ошибки.
+func WriteOperationError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, process.ErrInvalidTransition) {
+		status = http.StatusConflict
+	}
+	WriteJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/process"
+)
+
+func TestWriteOperationError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{&process.TransitionError{ModuleID: "com.a.app", From: process.StateStarting, To: process.StateStopping}, http.StatusConflict},
+		{fmt.Errorf("stop: %w", process.ErrInvalidTransition), http.StatusConflict},
+		{errors.New("grpc_addr is required"), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		WriteOperationError(rec, tt.err)
+		if rec.Code != tt.want {
+			t.Errorf("WriteOperationError(%v) status = %d, want %d", tt.err, rec.Code, tt.want)
+		}
+	}
+}
@@ -6,32 +6,87 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
+	"sort"
 	"time"
 
 	pb "github.com/GalitskyKK/nekkus-core/pkg/protocol"
 	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
 	"github.com/GalitskyKK/nekkus-hub/internal/process"
+	"github.com/GalitskyKK/nekkus-hub/internal/settings"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
 // ModuleSummary — ответ API по модулю с опциональными данными виджета.
 type ModuleSummary struct {
-	Manifest   manifest.ModuleManifest `json:"manifest"`
-	WidgetType string                 `json:"widget_type,omitempty"`
-	Payload    json.RawMessage         `json:"payload,omitempty"`
-	Error      string                  `json:"error,omitempty"`
-	Running    bool                    `json:"running"`
+	Manifest   manifest.ModuleManifest  `json:"manifest"`
+	WidgetType string                   `json:"widget_type,omitempty"`
+	Payload    json.RawMessage          `json:"payload,omitempty"`
+	Error      string                   `json:"error,omitempty"`
+	Running    bool                     `json:"running"`
+	Status     process.ModuleStatus     `json:"status"`
+	Health     *process.HealthStatus    `json:"health,omitempty"`
+	Metrics    *process.MetricsSample   `json:"metrics,omitempty"`
+	Supervisor process.SupervisorStatus `json:"supervisor"`
+	LastCrash  *LastCrash               `json:"last_crash,omitempty"`
+	Autostart  bool                     `json:"autostart"`
+	Addr       string                   `json:"addr,omitempty"`
+	// DataDir is the resolved --data-dir of the module (config.data_dir).
+	DataDir      string `json:"data_dir,omitempty"`
+	DataDirError string `json:"data_dir_error,omitempty"`
+	// Instances are the named instances of the module (the fields above describe the default one).
+	Instances []InstanceSummary `json:"instances,omitempty"`
+}
+
+// InstanceSummary — именованный экземпляр модуля: объявленный в настройках и/или запущенный.
+type InstanceSummary struct {
+	Instance  string                   `json:"instance"`
+	Defined   bool                     `json:"defined"`
+	Running   bool                     `json:"running"`
+	Addr      string                   `json:"addr,omitempty"`
+	Status    process.ModuleStatus     `json:"status"`
+	Overrides manifest.LaunchOverrides `json:"overrides"`
+	LastCrash *LastCrash               `json:"last_crash,omitempty"`
+	// Supervisor и Limits — политика и лимиты модуля с историей и применением для экземпляра.
+	Supervisor process.SupervisorStatus `json:"supervisor"`
+	Limits     process.LimitsStatus     `json:"limits"`
+}
+
+// LastCrash — краткая причина последнего падения модуля.
+type LastCrash struct {
+	Reason   string    `json:"reason"`
+	ExitedAt time.Time `json:"exited_at"`
 }
 
 // BuildModuleSummaries строит summary по всем модулям, для запущенных запрашивает виджеты.
-func BuildModuleSummaries(modules []manifest.ModuleManifest, manager *process.Manager) []ModuleSummary {
+func BuildModuleSummaries(modules []manifest.ModuleManifest, manager *process.Manager, store *settings.Store, modulesDir string) []ModuleSummary {
 	summaries := make([]ModuleSummary, 0, len(modules))
 	for _, module := range modules {
 		summary := ModuleSummary{Manifest: module}
 		summary.Running = manager.IsRunning(module.ID)
+		summary.Status = manager.Status(module.ID)
+		if summary.Running {
+			health := manager.Health(module.ID)
+			summary.Health = &health
+			if sample, ok := manager.LatestMetrics(module.ID); ok {
+				summary.Metrics = &sample
+			}
+		}
+		summary.Supervisor = manager.SupervisorStatus(module, "")
+		if crash, ok := manager.LastCrash(module.ID); ok {
+			summary.LastCrash = &LastCrash{Reason: crash.Reason, ExitedAt: crash.ExitedAt}
+		}
+		summary.Autostart = store.Autostart(module)
+		summary.Addr, _ = manager.Addr(module.ID)
+		if dataDir, err := process.ResolveDataDir(module, modulesDir); err != nil {
+			summary.DataDirError = err.Error()
+		} else {
+			summary.DataDir = dataDir
+		}
+		summary.Instances = BuildInstanceSummaries(module, manager, store)
 		if summary.Running {
-			widgetType, payload, err := fetchWidgetData(module.GrpcAddr)
+			widgetType, payload, err := fetchWidgetData(summary.Addr)
 			if err != nil {
 				summary.Error = err.Error()
 			} else {
@@ -44,9 +99,41 @@ func BuildModuleSummaries(modules []manifest.ModuleManifest, manager *process.Ma
 	return summaries
 }
 
+// BuildInstanceSummaries описывает экземпляры модуля из настроек и запущенные менеджером.
+func BuildInstanceSummaries(module manifest.ModuleManifest, manager *process.Manager, store *settings.Store) []InstanceSummary {
+	defined := store.ModuleInstances(module.ID)
+	names := append([]string{}, defined...)
+	for _, inst := range manager.Instances(module.ID) {
+		if !slices.Contains(names, inst.Instance) {
+			names = append(names, inst.Instance)
+		}
+	}
+	sort.Strings(names)
+
+	summaries := make([]InstanceSummary, 0, len(names))
+	for _, name := range names {
+		key := process.InstanceKey(module.ID, name)
+		summary := InstanceSummary{
+			Instance:   name,
+			Defined:    slices.Contains(defined, name),
+			Running:    manager.IsRunning(key),
+			Status:     manager.Status(key),
+			Overrides:  manager.LaunchOverrides(key),
+			Supervisor: manager.SupervisorStatus(module, name),
+			Limits:     manager.LimitsStatus(module, name),
+		}
+		summary.Addr, _ = manager.Addr(key)
+		if crash, ok := manager.LastCrash(key); ok {
+			summary.LastCrash = &LastCrash{Reason: crash.Reason, ExitedAt: crash.ExitedAt}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
 func fetchWidgetData(addr string) (string, json.RawMessage, error) {
 	if addr == "" {
-		return "", nil, fmt.Errorf("grpc_addr is not set in manifest")
+		return "", nil, fmt.Errorf("module has no grpc address")
 	}
 
 	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
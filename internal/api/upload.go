@@ -1,53 +1,100 @@
 package api
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
 )
 
 const multipartMaxBytes = 32 << 20
 
+// ModuleUpload is a parsed module upload: Install writes its files, Close removes the
+// temporary files of the form.
+type ModuleUpload struct {
+	ModuleID string
+	form     *multipart.Form
+}
+
 // AddModuleFromMultipart parses multipart form where each part key is a relative path (e.g. "manifest.json", "nekkus-net.exe").
 // manifest.json must be present; its "id" is used as the module folder name under modulesDir.
 func AddModuleFromMultipart(r *http.Request, modulesDir string) (string, error) {
+	upload, err := ParseModuleUpload(r)
+	if err != nil {
+		return "", err
+	}
+	defer upload.Close()
+	if err := upload.Install(modulesDir); err != nil {
+		return "", err
+	}
+	return upload.ModuleID, nil
+}
+
+// ParseModuleUpload reads the form of AddModuleFromMultipart and validates its manifest.json
+// (see manifest.Validate) without writing anything. The upload owns the form afterwards, so
+// it stays usable after the request has been answered; the caller must Close it.
+func ParseModuleUpload(r *http.Request) (*ModuleUpload, error) {
 	if err := r.ParseMultipartForm(multipartMaxBytes); err != nil {
-		return "", fmt.Errorf("parse form: %w", err)
+		return nil, fmt.Errorf("parse form: %w", err)
 	}
-	defer r.MultipartForm.RemoveAll()
+	upload := &ModuleUpload{form: r.MultipartForm}
+	// Иначе net/http удалит временные файлы формы сразу после ответа.
+	r.MultipartForm = nil
 
-	files := r.MultipartForm.File
+	files := upload.form.File
 	if files["manifest.json"] == nil || len(files["manifest.json"]) == 0 {
-		return "", fmt.Errorf("manifest.json is required")
+		_ = upload.Close()
+		return nil, fmt.Errorf("manifest.json is required")
 	}
 	manifestFile, err := files["manifest.json"][0].Open()
 	if err != nil {
-		return "", fmt.Errorf("open manifest: %w", err)
+		_ = upload.Close()
+		return nil, fmt.Errorf("open manifest: %w", err)
 	}
 	defer manifestFile.Close()
 	manifestData, err := io.ReadAll(manifestFile)
 	if err != nil {
-		return "", fmt.Errorf("read manifest: %w", err)
+		_ = upload.Close()
+		return nil, fmt.Errorf("read manifest: %w", err)
 	}
-	var manifest struct {
-		ID string `json:"id"`
+	// Предупреждения о миграции формата попадут в отчёт скана после установки.
+	mod, _, err := manifest.Decode(manifestData)
+	if err != nil {
+		_ = upload.Close()
+		return nil, fmt.Errorf("invalid manifest.json: %w", err)
 	}
-	if err := json.Unmarshal(manifestData, &manifest); err != nil {
-		return "", fmt.Errorf("invalid manifest.json: %w", err)
+	if mod.ID == "" {
+		_ = upload.Close()
+		return nil, fmt.Errorf("manifest.json must contain \"id\"")
 	}
-	if manifest.ID == "" {
-		return "", fmt.Errorf("manifest.json must contain \"id\"")
+	// ID становится именем папки модуля, поэтому проверяем манифест до записи файлов.
+	if err := mod.Validate(runtime.GOOS, runtime.GOARCH); err != nil {
+		_ = upload.Close()
+		return nil, err
 	}
-	moduleDir := filepath.Join(modulesDir, manifest.ID)
+	upload.ModuleID = mod.ID
+	return upload, nil
+}
+
+// Close removes the temporary files of the upload.
+func (u *ModuleUpload) Close() error {
+	return u.form.RemoveAll()
+}
+
+// Install writes the uploaded files to modulesDir/<module id>.
+func (u *ModuleUpload) Install(modulesDir string) error {
+	moduleDir := filepath.Join(modulesDir, u.ModuleID)
 	if err := os.MkdirAll(moduleDir, 0755); err != nil {
-		return "", fmt.Errorf("create module dir: %w", err)
+		return fmt.Errorf("create module dir: %w", err)
 	}
 
-	for key, headers := range files {
+	for key, headers := range u.form.File {
 		if key == "" {
 			continue
 		}
@@ -67,25 +114,25 @@ func AddModuleFromMultipart(r *http.Request, modulesDir string) (string, error)
 		for _, h := range headers {
 			f, openErr := h.Open()
 			if openErr != nil {
-				return "", fmt.Errorf("open %s: %w", key, openErr)
+				return fmt.Errorf("open %s: %w", key, openErr)
 			}
 			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
 				_ = f.Close()
-				return "", fmt.Errorf("mkdir for %s: %w", key, err)
+				return fmt.Errorf("mkdir for %s: %w", key, err)
 			}
 			dst, createErr := os.Create(target)
 			if createErr != nil {
 				_ = f.Close()
-				return "", fmt.Errorf("create %s: %w", key, createErr)
+				return fmt.Errorf("create %s: %w", key, createErr)
 			}
 			_, copyErr := io.Copy(dst, f)
 			_ = f.Close()
 			_ = dst.Close()
 			if copyErr != nil {
-				return "", fmt.Errorf("write %s: %w", key, copyErr)
+				return fmt.Errorf("write %s: %w", key, copyErr)
 			}
 			break
 		}
 	}
-	return manifest.ID, nil
+	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	pb "github.com/GalitskyKK/nekkus-core/pkg/protocol"
+	"github.com/GalitskyKK/nekkus-hub/internal/process"
 	"github.com/GalitskyKK/nekkus-hub/internal/registry"
 	"google.golang.org/grpc"
 )
@@ -11,18 +12,21 @@ import (
 // Server реализует pb.NekkusHubServer для вызовов модуль → hub.
 type Server struct {
 	pb.UnimplementedNekkusHubServer
-	registry *registry.Registry
+	registry  *registry.Registry
+	processes *process.Manager
 }
 
-// NewServer создаёт gRPC-сервер Hub с данным registry.
-func NewServer(reg *registry.Registry) *Server {
-	return &Server{registry: reg}
+// NewServer создаёт gRPC-сервер Hub с данным registry и менеджером процессов.
+func NewServer(reg *registry.Registry, procMgr *process.Manager) *Server {
+	return &Server{registry: reg, processes: procMgr}
 }
 
-// Register регистрирует модуль в registry.
+// Register регистрирует модуль в registry и сообщает менеджеру, что модуль готов;
+// grpc_port из ModuleInfo различает экземпляры одного модуля.
 func (s *Server) Register(ctx context.Context, req *pb.ModuleInfo) (*pb.RegisterResponse, error) {
 	pid := int32(0)
 	s.registry.RegisterModule(req.GetId(), req.GetVersion(), pid)
+	s.processes.NotifyRegistered(req.GetId(), int(req.GetGrpcPort()))
 	return &pb.RegisterResponse{
 		Success: true,
 		HubId:   "hub",
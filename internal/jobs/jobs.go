@@ -0,0 +1,162 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Job states.
+const (
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	StateCancelled = "cancelled"
+)
+
+// finishedTTL is how long a finished job stays available for polling.
+const finishedTTL = 10 * time.Minute
+
+// Func is the work of a job. It must return soon after ctx is cancelled.
+type Func func(ctx context.Context) (interface{}, error)
+
+// Job is a snapshot of an asynchronous operation.
+type Job struct {
+	ID         string      `json:"id"`
+	Kind       string      `json:"kind"`
+	ModuleID   string      `json:"module_id,omitempty"`
+	State      string      `json:"state"`
+	CreatedAt  time.Time   `json:"created_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// Done reports whether the job has finished.
+func (j Job) Done() bool {
+	return j.State != StateRunning
+}
+
+type job struct {
+	snapshot Job
+	cancel   context.CancelFunc
+}
+
+// Manager runs jobs in the background and keeps them for polling.
+type Manager struct {
+	ctx  context.Context
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+// NewManager creates an empty job Manager. Jobs run with contexts derived from ctx, so
+// cancelling ctx (on hub shutdown) cancels every running job.
+func NewManager(ctx context.Context) *Manager {
+	return &Manager{ctx: ctx, jobs: make(map[string]*job)}
+}
+
+// Submit runs fn in the background and returns the new job. While a job of the same kind
+// for the same module is running, Submit returns that job and false without running fn,
+// so a repeated request (a second click on Start) does not queue a duplicate operation.
+func (m *Manager) Submit(kind, moduleID string, fn Func) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked()
+	for _, j := range m.jobs {
+		if j.snapshot.Kind == kind && j.snapshot.ModuleID == moduleID && !j.snapshot.Done() {
+			return j.snapshot, false
+		}
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	j := &job{
+		snapshot: Job{
+			ID:        newID(),
+			Kind:      kind,
+			ModuleID:  moduleID,
+			State:     StateRunning,
+			CreatedAt: time.Now(),
+		},
+		cancel: cancel,
+	}
+	m.jobs[j.snapshot.ID] = j
+	go m.run(ctx, j, fn)
+	return j.snapshot, true
+}
+
+func (m *Manager) run(ctx context.Context, j *job, fn Func) {
+	result, err := fn(ctx)
+	finished := time.Now()
+
+	m.mu.Lock()
+	j.snapshot.FinishedAt = &finished
+	j.snapshot.Result = result
+	switch {
+	case err != nil && ctx.Err() != nil:
+		j.snapshot.State = StateCancelled
+		j.snapshot.Error = err.Error()
+	case err != nil:
+		j.snapshot.State = StateFailed
+		j.snapshot.Error = err.Error()
+	default:
+		j.snapshot.State = StateSucceeded
+	}
+	m.mu.Unlock()
+	j.cancel()
+}
+
+// Get returns a job by ID.
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return j.snapshot, true
+}
+
+// List returns the known jobs, newest first.
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked()
+	list := make([]Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		list = append(list, j.snapshot)
+	}
+	sort.Slice(list, func(i, k int) bool { return list[i].CreatedAt.After(list[k].CreatedAt) })
+	return list
+}
+
+// Cancel cancels the context of a running job; the job reports StateCancelled once its
+// work returns an error. Cancelling a finished job has no effect.
+func (m *Manager) Cancel(id string) (Job, bool) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		return Job{}, false
+	}
+	j.cancel()
+	return m.Get(id)
+}
+
+// pruneLocked drops jobs finished more than finishedTTL ago.
+func (m *Manager) pruneLocked() {
+	cutoff := time.Now().Add(-finishedTTL)
+	for id, j := range m.jobs {
+		if j.snapshot.FinishedAt != nil && j.snapshot.FinishedAt.Before(cutoff) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+func newID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,111 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitDone polls a job until it finishes.
+func waitDone(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		j, ok := m.Get(id)
+		if !ok {
+			t.Fatalf("job %s not found", id)
+		}
+		if j.Done() {
+			return j
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return Job{}
+}
+
+func TestJobStates(t *testing.T) {
+	tests := []struct {
+		name      string
+		fn        Func
+		wantState string
+		wantError string
+	}{
+		{
+			name:      "succeeded",
+			fn:        func(context.Context) (interface{}, error) { return "ok", nil },
+			wantState: StateSucceeded,
+		},
+		{
+			name:      "failed",
+			fn:        func(context.Context) (interface{}, error) { return nil, errors.New("boom") },
+			wantState: StateFailed,
+			wantError: "boom",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(context.Background())
+			release := make(chan struct{})
+			j, created := m.Submit("start", "com.a.app", func(ctx context.Context) (interface{}, error) {
+				<-release
+				return tt.fn(ctx)
+			})
+			if !created || j.State != StateRunning {
+				t.Fatalf("Submit = %+v, %v; want a new running job", j, created)
+			}
+			close(release)
+			j = waitDone(t, m, j.ID)
+			if j.State != tt.wantState || j.Error != tt.wantError || j.FinishedAt == nil {
+				t.Errorf("job = %+v, want state %s and error %q", j, tt.wantState, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestSubmitDeduplicatesRunningJob(t *testing.T) {
+	m := NewManager(context.Background())
+	release := make(chan struct{})
+	runs := 0
+	fn := func(context.Context) (interface{}, error) {
+		runs++
+		<-release
+		return nil, nil
+	}
+	first, _ := m.Submit("start", "com.a.app", fn)
+	second, created := m.Submit("start", "com.a.app", fn)
+	if created || second.ID != first.ID {
+		t.Errorf("second Submit = %s, %v; want the running job %s", second.ID, created, first.ID)
+	}
+	other, created := m.Submit("stop", "com.a.app", func(context.Context) (interface{}, error) { return nil, nil })
+	if !created || other.ID == first.ID {
+		t.Errorf("Submit of another kind = %s, %v; want a new job", other.ID, created)
+	}
+	close(release)
+	waitDone(t, m, first.ID)
+	if runs != 1 {
+		t.Errorf("fn ran %d times, want 1", runs)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewManager(ctx)
+	fn := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	j, _ := m.Submit("start", "com.a.app", fn)
+	m.Cancel(j.ID)
+	if j = waitDone(t, m, j.ID); j.State != StateCancelled {
+		t.Errorf("cancelled job state = %s, want %s", j.State, StateCancelled)
+	}
+
+	// Отмена контекста менеджера (остановка hub) отменяет все задачи.
+	j, _ = m.Submit("start", "com.a.other", fn)
+	cancel()
+	if j = waitDone(t, m, j.ID); j.State != StateCancelled {
+		t.Errorf("job state after shutdown = %s, want %s", j.State, StateCancelled)
+	}
+}
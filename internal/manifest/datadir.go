@@ -0,0 +1,45 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Data directory modes for DataDirPolicy.Mode.
+const (
+	// DataDirModule keeps data inside the module directory (storage_path, "data" by default).
+	DataDirModule = "module"
+	// DataDirSharedUserConfig uses Path under the user config dir, e.g. "nekkus/net" in
+	// %APPDATA% or ~/.config, so a module shares its data with its standalone runs.
+	DataDirSharedUserConfig = "shared-user-config"
+	// DataDirXDGData uses Path under $XDG_DATA_HOME (~/.local/share; %LOCALAPPDATA% on Windows).
+	DataDirXDGData = "xdg-data"
+	// DataDirCustom uses Path as is after variable expansion.
+	DataDirCustom = "custom"
+)
+
+// DataDirPolicy chooses where the hub puts the data directory of a module.
+// In manifest.json it is either a mode string or {"mode": "...", "path": "..."}.
+type DataDirPolicy struct {
+	Mode string `json:"mode"`
+	Path string `json:"path,omitempty"`
+}
+
+// UnmarshalJSON accepts both the string and the object form.
+func (p *DataDirPolicy) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		var mode string
+		if err := json.Unmarshal(data, &mode); err != nil {
+			return err
+		}
+		*p = DataDirPolicy{Mode: mode}
+		return nil
+	}
+	type plain DataDirPolicy
+	var v plain
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*p = DataDirPolicy(v)
+	return nil
+}
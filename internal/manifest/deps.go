@@ -0,0 +1,212 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Dependency is an entry of depends_on: a module ID with an optional version constraint.
+// In manifest.json it is either a plain ID string or {"id": "...", "version": ">=0.2.0"}.
+type Dependency struct {
+	ID      string `json:"id"`
+	Version string `json:"version,omitempty"`
+}
+
+// UnmarshalJSON accepts both the string and the object form.
+func (d *Dependency) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		var id string
+		if err := json.Unmarshal(data, &id); err != nil {
+			return err
+		}
+		*d = Dependency{ID: id}
+		return nil
+	}
+	type plain Dependency
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*d = Dependency(p)
+	return nil
+}
+
+// CheckDependencies validates depends_on across a set of manifests and returns an error
+// per rejected module: missing dependencies, unmet version constraints, cycles, and
+// dependencies on modules that are themselves rejected.
+func CheckDependencies(mods []ModuleManifest) map[string]error {
+	byID := make(map[string]ModuleManifest, len(mods))
+	for _, m := range mods {
+		byID[m.ID] = m
+	}
+	rejected := make(map[string]error)
+
+	for _, m := range mods {
+		for _, dep := range m.DependsOn {
+			if err := checkDependency(m, dep, byID); err != nil {
+				rejected[m.ID] = err
+				break
+			}
+		}
+	}
+
+	for _, cycle := range findCycles(mods, byID) {
+		err := fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+		for _, id := range cycle {
+			if _, ok := rejected[id]; !ok {
+				rejected[id] = err
+			}
+		}
+	}
+
+	// Модуль, зависящий от отклонённого, тоже не может быть запущен.
+	for changed := true; changed; {
+		changed = false
+		for _, m := range mods {
+			if _, ok := rejected[m.ID]; ok {
+				continue
+			}
+			for _, dep := range m.DependsOn {
+				if _, ok := rejected[dep.ID]; ok {
+					rejected[m.ID] = fmt.Errorf("%s: dependency %s is rejected", m.ID, dep.ID)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return rejected
+}
+
+func checkDependency(m ModuleManifest, dep Dependency, byID map[string]ModuleManifest) error {
+	if dep.ID == "" {
+		return fmt.Errorf("%s: depends_on entry without id", m.ID)
+	}
+	if dep.ID == m.ID {
+		return fmt.Errorf("%s: module depends on itself", m.ID)
+	}
+	target, ok := byID[dep.ID]
+	if !ok {
+		return fmt.Errorf("%s: missing dependency %s", m.ID, dep.ID)
+	}
+	if dep.Version == "" {
+		return nil
+	}
+	match, err := MatchVersion(dep.Version, target.Version)
+	if err != nil {
+		return fmt.Errorf("%s: dependency %s: %w", m.ID, dep.ID, err)
+	}
+	if !match {
+		return fmt.Errorf("%s: dependency %s %s is required, found %s", m.ID, dep.ID, dep.Version, target.Version)
+	}
+	return nil
+}
+
+// findCycles returns each dependency cycle once as a path that starts and ends with the same ID.
+func findCycles(mods []ModuleManifest, byID map[string]ModuleManifest) [][]string {
+	const (
+		unvisited = iota
+		inStack
+		done
+	)
+	state := make(map[string]int, len(mods))
+	var (
+		stack  []string
+		cycles [][]string
+		visit  func(id string)
+	)
+	visit = func(id string) {
+		state[id] = inStack
+		stack = append(stack, id)
+		for _, dep := range byID[id].DependsOn {
+			if _, ok := byID[dep.ID]; !ok || dep.ID == id {
+				continue
+			}
+			switch state[dep.ID] {
+			case unvisited:
+				visit(dep.ID)
+			case inStack:
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == dep.ID {
+						cycle := append(append([]string{}, stack[i:]...), dep.ID)
+						cycles = append(cycles, cycle)
+						break
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = done
+	}
+
+	ids := make([]string, 0, len(mods))
+	for _, m := range mods {
+		ids = append(ids, m.ID)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if state[id] == unvisited {
+			visit(id)
+		}
+	}
+	return cycles
+}
+
+// DependencyOrder returns the transitive dependencies of root followed by root itself,
+// each module after everything it depends on.
+func DependencyOrder(root ModuleManifest, lookup func(id string) (ModuleManifest, bool)) ([]ModuleManifest, error) {
+	var (
+		order    []ModuleManifest
+		visiting = make(map[string]bool)
+		visited  = make(map[string]bool)
+		visit    func(m ModuleManifest, path []string) error
+	)
+	visit = func(m ModuleManifest, path []string) error {
+		if visited[m.ID] {
+			return nil
+		}
+		path = append(path, m.ID)
+		if visiting[m.ID] {
+			return fmt.Errorf("dependency cycle: %s", strings.Join(path, " -> "))
+		}
+		visiting[m.ID] = true
+		for _, dep := range m.DependsOn {
+			target, ok := lookup(dep.ID)
+			if !ok {
+				return fmt.Errorf("%s: missing dependency %s", m.ID, dep.ID)
+			}
+			if err := checkDependency(m, dep, map[string]ModuleManifest{dep.ID: target}); err != nil {
+				return err
+			}
+			if err := visit(target, path); err != nil {
+				return err
+			}
+		}
+		visiting[m.ID] = false
+		visited[m.ID] = true
+		order = append(order, m)
+		return nil
+	}
+	if err := visit(root, nil); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// Dependents returns the IDs of modules in mods that directly depend on id.
+func Dependents(id string, mods []ModuleManifest) []string {
+	var ids []string
+	for _, m := range mods {
+		for _, dep := range m.DependsOn {
+			if dep.ID == id {
+				ids = append(ids, m.ID)
+				break
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,200 @@
+package manifest
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// mod builds a manifest with the given version and depends_on entries ("id" or "id@constraint").
+func mod(id, version string, deps ...string) ModuleManifest {
+	m := ModuleManifest{ID: id, Version: version}
+	for _, dep := range deps {
+		depID, constraint, _ := strings.Cut(dep, "@")
+		m.DependsOn = append(m.DependsOn, Dependency{ID: depID, Version: constraint})
+	}
+	return m
+}
+
+func TestDependencyOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		mods    []ModuleManifest
+		root    string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "no dependencies",
+			mods: []ModuleManifest{mod("com.a.app", "1.0.0")},
+			root: "com.a.app",
+			want: []string{"com.a.app"},
+		},
+		{
+			name: "chain",
+			mods: []ModuleManifest{
+				mod("com.a.app", "1.0.0", "com.a.api"),
+				mod("com.a.api", "1.0.0", "com.a.db"),
+				mod("com.a.db", "1.0.0"),
+			},
+			root: "com.a.app",
+			want: []string{"com.a.db", "com.a.api", "com.a.app"},
+		},
+		{
+			name: "diamond lists the shared dependency once",
+			mods: []ModuleManifest{
+				mod("com.a.app", "1.0.0", "com.a.left", "com.a.right"),
+				mod("com.a.left", "1.0.0", "com.a.base"),
+				mod("com.a.right", "1.0.0", "com.a.base"),
+				mod("com.a.base", "1.0.0"),
+			},
+			root: "com.a.app",
+			want: []string{"com.a.base", "com.a.left", "com.a.right", "com.a.app"},
+		},
+		{
+			name: "version constraint satisfied",
+			mods: []ModuleManifest{
+				mod("com.a.app", "1.0.0", "com.a.db@^0.2"),
+				mod("com.a.db", "0.2.7"),
+			},
+			root: "com.a.app",
+			want: []string{"com.a.db", "com.a.app"},
+		},
+		{
+			name: "version constraint not satisfied",
+			mods: []ModuleManifest{
+				mod("com.a.app", "1.0.0", "com.a.db@^0.2"),
+				mod("com.a.db", "0.9.0"),
+			},
+			root:    "com.a.app",
+			wantErr: "com.a.app: dependency com.a.db ^0.2 is required, found 0.9.0",
+		},
+		{
+			name:    "missing dependency",
+			mods:    []ModuleManifest{mod("com.a.app", "1.0.0", "com.a.db")},
+			root:    "com.a.app",
+			wantErr: "com.a.app: missing dependency com.a.db",
+		},
+		{
+			name: "cycle",
+			mods: []ModuleManifest{
+				mod("com.a.app", "1.0.0", "com.a.api"),
+				mod("com.a.api", "1.0.0", "com.a.db"),
+				mod("com.a.db", "1.0.0", "com.a.api"),
+			},
+			root:    "com.a.app",
+			wantErr: "dependency cycle: com.a.app -> com.a.api -> com.a.db -> com.a.api",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			byID := make(map[string]ModuleManifest, len(tt.mods))
+			for _, m := range tt.mods {
+				byID[m.ID] = m
+			}
+			lookup := func(id string) (ModuleManifest, bool) {
+				m, ok := byID[id]
+				return m, ok
+			}
+			order, err := DependencyOrder(byID[tt.root], lookup)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("DependencyOrder error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DependencyOrder: %v", err)
+			}
+			var ids []string
+			for _, m := range order {
+				ids = append(ids, m.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("DependencyOrder = %q, want %q", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	tests := []struct {
+		name string
+		mods []ModuleManifest
+		// want maps rejected module IDs to a substring of their error.
+		want map[string]string
+	}{
+		{
+			name: "all satisfied",
+			mods: []ModuleManifest{
+				mod("com.a.app", "1.0.0", "com.a.db@>=1.2, <2"),
+				mod("com.a.db", "1.4.0"),
+			},
+			want: map[string]string{},
+		},
+		{
+			name: "missing dependency rejects its dependents",
+			mods: []ModuleManifest{
+				mod("com.a.app", "1.0.0", "com.a.api"),
+				mod("com.a.api", "1.0.0", "com.a.db"),
+				mod("com.a.other", "1.0.0"),
+			},
+			want: map[string]string{
+				"com.a.api": "missing dependency com.a.db",
+				"com.a.app": "dependency com.a.api is rejected",
+			},
+		},
+		{
+			name: "unmet version",
+			mods: []ModuleManifest{
+				mod("com.a.app", "1.0.0", "com.a.db@>1"),
+				mod("com.a.db", "1.9.9"),
+			},
+			want: map[string]string{"com.a.app": "com.a.db >1 is required, found 1.9.9"},
+		},
+		{
+			name: "self dependency",
+			mods: []ModuleManifest{mod("com.a.app", "1.0.0", "com.a.app")},
+			want: map[string]string{"com.a.app": "depends on itself"},
+		},
+		{
+			name: "cycle rejects its members and dependents",
+			mods: []ModuleManifest{
+				mod("com.a.app", "1.0.0", "com.a.x"),
+				mod("com.a.x", "1.0.0", "com.a.y"),
+				mod("com.a.y", "1.0.0", "com.a.z"),
+				mod("com.a.z", "1.0.0", "com.a.x"),
+				mod("com.a.free", "1.0.0"),
+			},
+			want: map[string]string{
+				"com.a.x":   "dependency cycle: com.a.x -> com.a.y -> com.a.z -> com.a.x",
+				"com.a.y":   "dependency cycle: com.a.x -> com.a.y -> com.a.z -> com.a.x",
+				"com.a.z":   "dependency cycle: com.a.x -> com.a.y -> com.a.z -> com.a.x",
+				"com.a.app": "dependency com.a.x is rejected",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rejected := CheckDependencies(tt.mods)
+			var got, want []string
+			for id := range rejected {
+				got = append(got, id)
+			}
+			for id := range tt.want {
+				want = append(want, id)
+			}
+			sort.Strings(got)
+			sort.Strings(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("rejected = %q, want %q", got, want)
+			}
+			for id, substr := range tt.want {
+				if err := rejected[id]; !strings.Contains(err.Error(), substr) {
+					t.Errorf("%s: error %q does not contain %q", id, err, substr)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// LocalFileName is an optional developer override next to manifest.json. It is not shipped
+// with modules and typically points the hub at a locally built executable.
+const LocalFileName = "manifest.local.json"
+
+// LocalOverride is the content of manifest.local.json.
+type LocalOverride struct {
+	Executable  map[string]string `json:"executable,omitempty"`
+	SearchPaths []string          `json:"search_paths,omitempty"`
+}
+
+// ExecutableFor returns the executable name for goos/goarch, preferring the GOOS/GOARCH key.
+func (m ModuleManifest) ExecutableFor(goos, goarch string) string {
+	if name := m.Executable[goos+"/"+goarch]; name != "" {
+		return name
+	}
+	return m.Executable[goos]
+}
+
+// Apply merges o into m: executable entries replace the manifest ones per key, and
+// search paths are tried before the manifest ones.
+func (o LocalOverride) Apply(m *ModuleManifest) {
+	if len(o.Executable) > 0 {
+		merged := make(map[string]string, len(m.Executable)+len(o.Executable))
+		for key, name := range m.Executable {
+			merged[key] = name
+		}
+		for key, name := range o.Executable {
+			merged[key] = name
+		}
+		m.Executable = merged
+	}
+	if len(o.SearchPaths) > 0 {
+		m.SearchPaths = append(append([]string{}, o.SearchPaths...), m.SearchPaths...)
+	}
+}
+
+// LoadLocalOverride reads manifest.local.json from moduleDir; a missing file is not an error,
+// unknown keys are (a *ValidationError).
+func LoadLocalOverride(moduleDir string) (LocalOverride, bool, error) {
+	var o LocalOverride
+	data, err := os.ReadFile(filepath.Join(moduleDir, LocalFileName))
+	if os.IsNotExist(err) {
+		return o, false, nil
+	}
+	if err != nil {
+		return o, false, err
+	}
+	if err := json.Unmarshal(data, &o); err != nil {
+		return o, false, fmt.Errorf("%s: %w", LocalFileName, err)
+	}
+	if err := checkUnknownFields(data, reflect.TypeOf(o)); err != nil {
+		return o, false, fmt.Errorf("%s: %w", LocalFileName, err)
+	}
+	return o, true, nil
+}
@@ -9,16 +9,84 @@ type WidgetConfig struct {
 	SupportsResize bool   `json:"supports_resize"`
 }
 
+// Restart modes for RestartPolicy.Mode.
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+// RestartPolicy describes how the hub supervises a module process that exits on its own.
+// Durations use time.ParseDuration syntax; empty fields fall back to hub defaults.
+type RestartPolicy struct {
+	Mode           string `json:"mode"`
+	MaxRestarts    int    `json:"max_restarts"`
+	Window         string `json:"window"`
+	BackoffInitial string `json:"backoff_initial"`
+	BackoffMax     string `json:"backoff_max"`
+}
+
+// HealthCheck configures periodic health checks of a running module.
+// Durations use time.ParseDuration syntax; empty fields fall back to hub defaults.
+type HealthCheck struct {
+	Disabled         bool   `json:"disabled,omitempty"`
+	Interval         string `json:"interval"`
+	Timeout          string `json:"timeout"`
+	FailureThreshold int    `json:"failure_threshold"`
+	RestartOnFailure bool   `json:"restart_on_failure"`
+}
+
+// ResourceLimits caps the resources of a module process; they are enforced on Linux only.
+// MaxMemory is bytes with an optional K/M/G suffix ("512M"); CPUQuota is a share of one
+// CPU ("50%" or "1.5"); CPUWeight is the cgroup v2 cpu.weight (1-10000).
+type ResourceLimits struct {
+	MaxMemory    string `json:"max_memory,omitempty"`
+	CPUWeight    int    `json:"cpu_weight,omitempty"`
+	CPUQuota     string `json:"cpu_quota,omitempty"`
+	MaxOpenFiles int    `json:"max_open_files,omitempty"`
+	MaxProcesses int    `json:"max_processes,omitempty"`
+}
+
 // ModuleManifest is the parsed manifest.json of a module.
+// Executable is keyed by GOOS or GOOS/GOARCH ("linux/arm64"); the more specific key wins.
+// SearchPaths are the directories searched for it in order (see ExpandVars in pathutil).
+// Args and Env values are Go templates rendered at launch, e.g. "{{if .ShowUI}}file{{else}}none{{end}}".
+// EnvPassthrough names hub variables ("SSL_CERT_FILE", "HTTP_*") kept in the isolated env mode.
+// ManifestVersion is the format of the file (see Decode); a decoded manifest is always CurrentVersion.
 type ModuleManifest struct {
-	ID          string            `json:"id"`
-	Name        string            `json:"name"`
-	Description string            `json:"description"`
-	Version     string            `json:"version"`
-	Widget      WidgetConfig      `json:"widget"`
-	GrpcAddr    string            `json:"grpc_addr"`
-	Executable  map[string]string `json:"executable"`
-	Config      *struct {
-		StoragePath string `json:"storage_path"`
-	} `json:"config"`
+	ManifestVersion int               `json:"manifest_version,omitempty"`
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	Description     string            `json:"description"`
+	Version         string            `json:"version"`
+	Widget          WidgetConfig      `json:"widget"`
+	GrpcAddr        string            `json:"grpc_addr"`
+	Executable      map[string]string `json:"executable"`
+	SearchPaths     []string          `json:"search_paths,omitempty"`
+	Args            []string          `json:"args,omitempty"`
+	Env             map[string]string `json:"env,omitempty"`
+	EnvPassthrough  []string          `json:"env_passthrough,omitempty"`
+	Restart         *RestartPolicy    `json:"restart,omitempty"`
+	StopTimeout     string            `json:"stop_timeout,omitempty"`
+	ReadyTimeout    string            `json:"ready_timeout,omitempty"`
+	Health          *HealthCheck      `json:"health,omitempty"`
+	Limits          *ResourceLimits   `json:"limits,omitempty"`
+	DependsOn       []Dependency      `json:"depends_on,omitempty"`
+	Autostart       bool              `json:"autostart,omitempty"`
+	Config          *ModuleConfig     `json:"config"`
+}
+
+// ModuleConfig is the config section of a module manifest.
+// StoragePath is the data directory inside the module directory for the "module" data_dir mode.
+type ModuleConfig struct {
+	StoragePath string         `json:"storage_path"`
+	DataDir     *DataDirPolicy `json:"data_dir,omitempty"`
+}
+
+// LaunchOverrides are per-module launch settings added by the user on top of the manifest:
+// Args are appended to the manifest args, Env entries replace manifest ones with the same key.
+type LaunchOverrides struct {
+	Args     []string          `json:"args,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	LogLevel string            `json:"log_level,omitempty"`
 }
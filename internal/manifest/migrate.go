@@ -0,0 +1,106 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// CurrentVersion is the manifest format (manifest_version) understood by this hub. Older
+// formats are upgraded by Decode; manifests without manifest_version are version 0.
+const CurrentVersion = 1
+
+// NewerVersionError is returned by Decode for a manifest written for a newer hub.
+type NewerVersionError struct {
+	Version int
+}
+
+func (e *NewerVersionError) Error() string {
+	return fmt.Sprintf("manifest_version %d is newer than this hub supports (%d); update the hub", e.Version, CurrentVersion)
+}
+
+// migration upgrades a manifest document by one version. It returns warnings for the module
+// author and whether it modified doc.
+type migration func(doc map[string]json.RawMessage) (warnings []string, changed bool, err error)
+
+// migrations[v] upgrades version v to v+1.
+var migrations = map[int]migration{
+	0: migrateV0,
+}
+
+// migrateV0 upgrades manifests written before manifest_version existed. Their shape is that
+// of version 1, so nothing is changed. Hubs of that time looked up some executables and data
+// directories on their own, so missing search_paths and config.data_dir are reported: the
+// module now gets the defaults of every module.
+func migrateV0(doc map[string]json.RawMessage) ([]string, bool, error) {
+	warnings := []string{fmt.Sprintf("manifest_version is missing; add \"manifest_version\": %d", CurrentVersion)}
+	if _, ok := doc["search_paths"]; !ok {
+		warnings = append(warnings, "search_paths is missing; the executable is looked up in the module directory only")
+	}
+	config := map[string]json.RawMessage{}
+	if raw, ok := doc["config"]; ok && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, false, fmt.Errorf("config: %w", err)
+		}
+	}
+	if _, ok := config["data_dir"]; !ok {
+		warnings = append(warnings, "config.data_dir is missing; the module data is kept in the module directory")
+	}
+	return warnings, false, nil
+}
+
+// Decode parses manifest.json data of any supported format, upgrading older formats through
+// the migration chain, and returns the manifest with warnings about the upgrade. A manifest
+// newer than CurrentVersion is refused with *NewerVersionError, one with keys the format does
+// not declare with a *ValidationError listing them (the decoded manifest is returned too).
+// JSON errors are those of encoding/json and refer to positions in data.
+func Decode(data []byte) (ModuleManifest, []string, error) {
+	var m ModuleManifest
+	var head struct {
+		ManifestVersion int `json:"manifest_version"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return m, nil, err
+	}
+	version := head.ManifestVersion
+	switch {
+	case version < 0:
+		return m, nil, fmt.Errorf("invalid manifest_version %d", version)
+	case version > CurrentVersion:
+		return m, nil, &NewerVersionError{Version: version}
+	}
+
+	var warnings []string
+	if version < CurrentVersion {
+		var doc map[string]json.RawMessage
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return m, nil, err
+		}
+		modified := false
+		for ; version < CurrentVersion; version++ {
+			w, changed, err := migrations[version](doc)
+			if err != nil {
+				return m, nil, fmt.Errorf("migrate manifest from version %d: %w", version, err)
+			}
+			warnings = append(warnings, w...)
+			modified = modified || changed
+		}
+		// Без изменений разбираем исходные данные, чтобы позиции ошибок указывали в файл.
+		if modified {
+			migrated, err := json.Marshal(doc)
+			if err != nil {
+				return m, nil, err
+			}
+			data = migrated
+		}
+	}
+
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, nil, err
+	}
+	m.ManifestVersion = CurrentVersion
+	if err := checkUnknownFields(data, reflect.TypeOf(m)); err != nil {
+		return m, warnings, err
+	}
+	return m, warnings, nil
+}
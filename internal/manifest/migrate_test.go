@@ -0,0 +1,101 @@
+package manifest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeMigratesVersion0(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		warnings int
+	}{
+		{
+			name:     "missing search_paths and data_dir reported",
+			data:     `{"id": "com.nekkus.net", "version": "0.1.0", "executable": {"linux": "nekkus-net"}}`,
+			warnings: 3,
+		},
+		{
+			name:     "config without data_dir",
+			data:     `{"id": "com.example.other", "config": {"storage_path": "data"}}`,
+			warnings: 3,
+		},
+		{
+			name:     "explicit settings",
+			data:     `{"id": "com.nekkus.net", "search_paths": ["${moduleDir}"], "config": {"data_dir": "module"}}`,
+			warnings: 1,
+		},
+		{
+			name:     "current version untouched",
+			data:     `{"manifest_version": 1, "id": "com.nekkus.net"}`,
+			warnings: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, warnings, err := Decode([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if m.ManifestVersion != CurrentVersion {
+				t.Errorf("manifest_version = %d, want %d", m.ManifestVersion, CurrentVersion)
+			}
+			if len(warnings) != tt.warnings {
+				t.Errorf("warnings = %q, want %d", warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestDecodeVersion0KeepsFields(t *testing.T) {
+	m, _, err := Decode([]byte(`{"id": "com.nekkus.net", "config": {"storage_path": "data"}}`))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	// Миграция не подставляет значения конкретного модуля.
+	if m.SearchPaths != nil {
+		t.Errorf("search_paths = %q, want none", m.SearchPaths)
+	}
+	if m.Config == nil || m.Config.StoragePath != "data" || m.Config.DataDir != nil {
+		t.Errorf("config = %+v, want storage_path only", m.Config)
+	}
+}
+
+func TestDecodeRefusesNewerVersion(t *testing.T) {
+	_, _, err := Decode([]byte(`{"manifest_version": 99, "id": "com.example.other"}`))
+	var newer *NewerVersionError
+	if !errors.As(err, &newer) || newer.Version != 99 {
+		t.Fatalf("Decode error = %v, want *NewerVersionError for 99", err)
+	}
+}
+
+func TestDecodeRejectsUnknownFields(t *testing.T) {
+	data := `{
+		"$schema": "http://localhost:9000/api/manifest/schema",
+		"manifest_version": 1,
+		"id": "com.example.other",
+		"Name": "Other",
+		"helth": {"interval": "10s"},
+		"health": {"restart_on_fail": true},
+		"depends_on": ["com.example.base", {"id": "com.example.db", "versoin": "^1.0.0"}],
+		"config": {"data_dir": {"mode": "custom", "path": "/srv", "owner": "me"}}
+	}`
+	m, _, err := Decode([]byte(data))
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Decode error = %v, want *ValidationError", err)
+	}
+	var fields []string
+	for _, f := range invalid.Fields {
+		fields = append(fields, f.Field)
+	}
+	want := []string{"config.data_dir.owner", "depends_on[1].versoin", "health.restart_on_fail", "helth"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("unknown fields = %q, want %q", fields, want)
+	}
+	if m.ID != "com.example.other" || m.Name != "Other" {
+		t.Errorf("decoded manifest = %q %q, want the known fields", m.ID, m.Name)
+	}
+}
@@ -0,0 +1,112 @@
+package manifest
+
+import "reflect"
+
+const (
+	semverPattern   = `^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`
+	durationPattern = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	// grpcAddrPattern is a syntactic approximation of validateGrpcAddr.
+	grpcAddrPattern = `^(auto|(localhost|127(\.[0-9]{1,3}){3}|\[::1\]):[0-9]{1,5})$`
+)
+
+type schema = map[string]interface{}
+
+// fieldSchemas are the keywords added to the generated schema of a field, by JSON path.
+var fieldSchemas = map[string]schema{
+	"manifest_version":         {"minimum": 0, "maximum": CurrentVersion, "description": "Manifest format; omitted means 0 (upgraded automatically)."},
+	"id":                       {"pattern": idPattern.String(), "maxLength": maxIDLength, "description": "Reverse-DNS module identifier, also the module folder name."},
+	"version":                  {"pattern": semverPattern},
+	"grpc_addr":                {"pattern": grpcAddrPattern, "description": `"auto" or a loopback host:port.`},
+	"executable":               {"minProperties": 1, "description": "Executable name by GOOS or GOOS/GOARCH; the current platform must have an entry."},
+	"args":                     {"description": "Go templates rendered at launch."},
+	"env":                      {"description": "Go templates rendered at launch."},
+	"widget.update_interval":   {"pattern": durationPattern},
+	"widget.height":            {"minimum": 0},
+	"stop_timeout":             {"pattern": durationPattern},
+	"ready_timeout":            {"pattern": durationPattern},
+	"restart.mode":             {"enum": []string{RestartNever, RestartOnFailure, RestartAlways}},
+	"restart.max_restarts":     {"minimum": 0},
+	"restart.window":           {"pattern": durationPattern},
+	"restart.backoff_initial":  {"pattern": durationPattern},
+	"restart.backoff_max":      {"pattern": durationPattern},
+	"health.interval":          {"pattern": durationPattern},
+	"health.timeout":           {"pattern": durationPattern},
+	"health.failure_threshold": {"minimum": 0},
+	"limits.cpu_weight":        {"minimum": 1, "maximum": 10000},
+}
+
+// typeSchemas are the schemas of types with a custom JSON form.
+var typeSchemas = map[reflect.Type]func() schema{
+	reflect.TypeOf(Dependency{}): func() schema {
+		id := schema{"type": "string", "pattern": idPattern.String()}
+		return schema{"oneOf": []schema{id, {
+			"type":                 "object",
+			"properties":           schema{"id": id, "version": schema{"type": "string", "description": `Constraint like ">=0.2.0, <1.0.0".`}},
+			"required":             []string{"id"},
+			"additionalProperties": false,
+		}}}
+	},
+	reflect.TypeOf(DataDirPolicy{}): func() schema {
+		mode := schema{"type": "string", "enum": []string{DataDirModule, DataDirSharedUserConfig, DataDirXDGData, DataDirCustom}}
+		return schema{"oneOf": []schema{mode, {
+			"type":                 "object",
+			"properties":           schema{"mode": mode, "path": schema{"type": "string"}},
+			"required":             []string{"mode"},
+			"additionalProperties": false,
+		}}}
+	},
+}
+
+// JSONSchema returns a JSON Schema (draft 2020-12) of manifest.json generated from
+// ModuleManifest. It covers what a schema can express; Validate remains authoritative
+// (e.g. for the executable of the current platform).
+func JSONSchema() map[string]interface{} {
+	s := typeSchema(reflect.TypeOf(ModuleManifest{}), "")
+	s["properties"].(schema)[schemaKey] = schema{"type": "string", "description": "JSON Schema of the file, for editors."}
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["title"] = "nekkus module manifest"
+	s["required"] = []string{"id", "version", "grpc_addr", "executable"}
+	return s
+}
+
+func typeSchema(t reflect.Type, path string) schema {
+	if custom, ok := typeSchemas[t]; ok {
+		return custom()
+	}
+	var s schema
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem(), path)
+	case reflect.String:
+		s = schema{"type": "string"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		s = schema{"type": "integer"}
+	case reflect.Bool:
+		s = schema{"type": "boolean"}
+	case reflect.Slice:
+		s = schema{"type": "array", "items": typeSchema(t.Elem(), path+"[]")}
+	case reflect.Map:
+		s = schema{"type": "object", "additionalProperties": typeSchema(t.Elem(), path+".*")}
+	case reflect.Struct:
+		props := schema{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := jsonName(f)
+			if name == "" {
+				continue
+			}
+			fieldPath := name
+			if path != "" {
+				fieldPath = path + "." + name
+			}
+			props[name] = typeSchema(f.Type, fieldPath)
+		}
+		s = schema{"type": "object", "properties": props, "additionalProperties": false}
+	default:
+		s = schema{}
+	}
+	for k, v := range fieldSchemas[path] {
+		s[k] = v
+	}
+	return s
+}
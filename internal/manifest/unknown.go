@@ -0,0 +1,102 @@
+package manifest
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// schemaKey may reference the JSON Schema at the top of a document for editors.
+const schemaKey = "$schema"
+
+// checkUnknownFields returns a *ValidationError listing the keys of the JSON document data
+// that t does not declare, by path ("health.restart_on_fail", "depends_on[0].versoin"), so a
+// misspelt setting is not silently ignored. Keys match fields case-insensitively, as in
+// encoding/json; data must already be known to decode into t.
+func checkUnknownFields(data []byte, t reflect.Type) error {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	var paths []string
+	collectUnknownFields(doc, t, "", &paths)
+	if len(paths) == 0 {
+		return nil
+	}
+	sort.Strings(paths)
+	var v validator
+	for _, path := range paths {
+		v.add(path, "unknown field")
+	}
+	return &ValidationError{Fields: v.fields}
+}
+
+func collectUnknownFields(value interface{}, t reflect.Type, path string, paths *[]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		// Не объект — строковая форма Dependency или DataDirPolicy.
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for key, item := range obj {
+			// "$schema" в корне указывает редактору на JSON Schema манифеста.
+			if path == "" && key == schemaKey {
+				continue
+			}
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			f, known := fieldByJSONName(t, key)
+			if !known {
+				*paths = append(*paths, keyPath)
+				continue
+			}
+			collectUnknownFields(item, f.Type, keyPath, paths)
+		}
+	case reflect.Slice:
+		items, _ := value.([]interface{})
+		for i, item := range items {
+			collectUnknownFields(item, t.Elem(), path+"["+strconv.Itoa(i)+"]", paths)
+		}
+	case reflect.Map:
+		obj, _ := value.(map[string]interface{})
+		for key, item := range obj {
+			collectUnknownFields(item, t.Elem(), path+"."+key, paths)
+		}
+	}
+}
+
+// fieldByJSONName finds the field of struct type t decoded from key: an exact match of the
+// json name first, then a case-insensitive one.
+func fieldByJSONName(t reflect.Type, key string) (reflect.StructField, bool) {
+	var folded reflect.StructField
+	found := false
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := jsonName(f)
+		switch {
+		case name == "":
+		case name == key:
+			return f, true
+		case !found && strings.EqualFold(name, key):
+			folded, found = f, true
+		}
+	}
+	return folded, found
+}
+
+// jsonName is the JSON key of a struct field, or "" for a field that is not encoded.
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,152 @@
+package manifest
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AutoAddr in grpc_addr asks the hub to pick a free loopback port.
+const AutoAddr = "auto"
+
+const maxIDLength = 128
+
+// idPattern is a reverse-DNS identifier ("com.nekkus.net"): it is also the folder name of the
+// module, so it cannot contain separators or "..".
+var idPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*(\.[A-Za-z][A-Za-z0-9_-]*)+$`)
+
+// FieldError is a problem with one manifest field; Field is its JSON path ("widget.height").
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every problem Validate found in a manifest.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+": "+f.Message)
+	}
+	return "invalid manifest: " + strings.Join(parts, "; ")
+}
+
+// ValidateID checks that id is a reverse-DNS module identifier.
+func ValidateID(id string) error {
+	if len(id) > maxIDLength || !idPattern.MatchString(id) {
+		return fmt.Errorf("%q is not a reverse-DNS identifier like com.example.module", id)
+	}
+	return nil
+}
+
+// Validate checks the manifest of a module that should run on goos/goarch: the identifier,
+// semver version, a loopback grpc_addr, durations, enums and an executable for the platform.
+// It returns a *ValidationError listing every problem.
+func (m ModuleManifest) Validate(goos, goarch string) error {
+	var v validator
+	if err := ValidateID(m.ID); err != nil {
+		v.add("id", err.Error())
+	}
+	if _, err := ParseVersion(m.Version); err != nil {
+		v.add("version", "must be a semantic version like 1.2.3")
+	}
+	if err := validateGrpcAddr(m.GrpcAddr); err != nil {
+		v.add("grpc_addr", err.Error())
+	}
+	if m.ExecutableFor(goos, goarch) == "" {
+		v.add("executable", fmt.Sprintf("no entry for %s or %s/%s", goos, goos, goarch))
+	}
+
+	v.duration("widget.update_interval", m.Widget.UpdateInterval)
+	if m.Widget.Height < 0 {
+		v.add("widget.height", "must not be negative")
+	}
+	v.duration("stop_timeout", m.StopTimeout)
+	v.duration("ready_timeout", m.ReadyTimeout)
+	if r := m.Restart; r != nil {
+		switch r.Mode {
+		case "", RestartNever, RestartOnFailure, RestartAlways:
+		default:
+			v.add("restart.mode", fmt.Sprintf("unknown mode %q", r.Mode))
+		}
+		if r.MaxRestarts < 0 {
+			v.add("restart.max_restarts", "must not be negative")
+		}
+		v.duration("restart.window", r.Window)
+		v.duration("restart.backoff_initial", r.BackoffInitial)
+		v.duration("restart.backoff_max", r.BackoffMax)
+	}
+	if h := m.Health; h != nil {
+		v.duration("health.interval", h.Interval)
+		v.duration("health.timeout", h.Timeout)
+		if h.FailureThreshold < 0 {
+			v.add("health.failure_threshold", "must not be negative")
+		}
+	}
+	for i, dep := range m.DependsOn {
+		field := "depends_on[" + strconv.Itoa(i) + "]"
+		if err := ValidateID(dep.ID); err != nil {
+			v.add(field+".id", err.Error())
+		}
+		if dep.Version != "" {
+			if err := ValidateConstraint(dep.Version); err != nil {
+				v.add(field+".version", err.Error())
+			}
+		}
+	}
+	if m.Config != nil && m.Config.DataDir != nil {
+		switch mode := m.Config.DataDir.Mode; mode {
+		case DataDirModule, DataDirSharedUserConfig, DataDirXDGData, DataDirCustom:
+		default:
+			v.add("config.data_dir.mode", fmt.Sprintf("unknown mode %q", mode))
+		}
+	}
+
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: v.fields}
+}
+
+// validateGrpcAddr accepts AutoAddr or a loopback host:port: modules must not listen on
+// other interfaces.
+func validateGrpcAddr(addr string) error {
+	if addr == AutoAddr {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("must be %q or a loopback host:port like 127.0.0.1:19001", AutoAddr)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("host %q is not a loopback address", host)
+	}
+	return nil
+}
+
+type validator struct {
+	fields []FieldError
+}
+
+func (v *validator) add(field, message string) {
+	v.fields = append(v.fields, FieldError{Field: field, Message: message})
+}
+
+// duration checks an optional positive time.ParseDuration value.
+func (v *validator) duration(field, value string) {
+	if value == "" {
+		return
+	}
+	if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+		v.add(field, fmt.Sprintf("%q is not a positive duration like 10s", value))
+	}
+}
@@ -0,0 +1,247 @@
+package manifest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version (build metadata is ignored).
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+}
+
+// ParseVersion parses "1.2.3", "v1.2.3-beta.1" or "1.2.3+build".
+func ParseVersion(s string) (Version, error) {
+	v, _, err := parseVersion(s, false)
+	return v, err
+}
+
+// parseVersion parses a version; partial allows "1" and "1.2" as used in constraints. It also
+// returns the number of components given.
+func parseVersion(s string, partial bool) (Version, int, error) {
+	var v Version
+	raw := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(raw, '+'); i >= 0 {
+		raw = raw[:i]
+	}
+	if i := strings.IndexByte(raw, '-'); i >= 0 {
+		v.Prerelease = raw[i+1:]
+		raw = raw[:i]
+		if v.Prerelease == "" {
+			return v, 0, fmt.Errorf("invalid version %q: empty prerelease", s)
+		}
+	}
+	parts := strings.Split(raw, ".")
+	if len(parts) > 3 || (!partial && len(parts) != 3) || (v.Prerelease != "" && len(parts) != 3) {
+		return v, 0, fmt.Errorf("invalid version %q: expected MAJOR.MINOR.PATCH", s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (len(part) > 1 && part[0] == '0') {
+			return v, 0, fmt.Errorf("invalid version %q", s)
+		}
+		*nums[i] = n
+	}
+	return v, len(parts), nil
+}
+
+// Compare returns -1, 0 or 1 by semver precedence: a prerelease sorts before its release,
+// and prerelease identifiers compare numerically when both are numbers ("rc.9" < "rc.10").
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	switch {
+	case v.Prerelease == o.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case o.Prerelease == "":
+		return -1
+	}
+	a, b := strings.Split(v.Prerelease, "."), strings.Split(o.Prerelease, ".")
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareIdentifier(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return cmpInt(len(a), len(b))
+}
+
+// compareIdentifier compares prerelease identifiers: numeric ones numerically and before
+// alphanumeric ones, which compare in ASCII order.
+func compareIdentifier(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		switch {
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// MatchVersion reports whether version satisfies constraint. A constraint is a
+// comma-separated list of terms, all of which must hold: "*", "1.2.3", "=1.2.3",
+// ">=1.2", ">1", "<=2.0.0", "<2", "^1.2" and "~1.2.3", with the npm semantics of partial
+// versions ("1.2" is 1.2.x, so ">1" means >=2.0.0 and "<=2.0" includes 2.0.5), carets that
+// pin the first non-zero component ("^0.2" is >=0.2.0 <0.3.0) and tildes that pin the minor.
+// A prerelease version only matches a constraint with a term naming a prerelease of the same
+// MAJOR.MINOR.PATCH, so "^1.2" does not match 2.0.0-rc.1.
+func MatchVersion(constraint, version string) (bool, error) {
+	v, err := ParseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	allowPrerelease := v.Prerelease == ""
+	for _, term := range strings.Split(constraint, ",") {
+		comparators, err := parseTerm(strings.TrimSpace(term))
+		if err != nil {
+			return false, err
+		}
+		for _, c := range comparators {
+			if !c.match(v) {
+				return false, nil
+			}
+			if c.bound.Prerelease != "" && c.bound.Major == v.Major && c.bound.Minor == v.Minor && c.bound.Patch == v.Patch {
+				allowPrerelease = true
+			}
+		}
+	}
+	return allowPrerelease, nil
+}
+
+// ValidateConstraint checks constraint syntax without a version to match.
+func ValidateConstraint(constraint string) error {
+	for _, term := range strings.Split(constraint, ",") {
+		if _, err := parseTerm(strings.TrimSpace(term)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// comparator is a primitive condition "op bound" with op one of =, >, >=, <, <=.
+type comparator struct {
+	op    string
+	bound Version
+}
+
+func (c comparator) match(v Version) bool {
+	cmp := v.Compare(c.bound)
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	default: // "="
+		return cmp == 0
+	}
+}
+
+// parseTerm turns a constraint term into the comparators that all must hold.
+func parseTerm(term string) ([]comparator, error) {
+	if term == "" || term == "*" {
+		return nil, nil
+	}
+	op := ""
+	for _, candidate := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(term, candidate) {
+			op = candidate
+			break
+		}
+	}
+	v, n, err := parseVersion(strings.TrimSpace(term[len(op):]), true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version constraint %q", term)
+	}
+	// Частичная версия — диапазон [v, next): "1.2" это 1.2.x, "1" — 1.x.x.
+	next := v
+	switch n {
+	case 1:
+		next = Version{Major: v.Major + 1}
+	case 2:
+		next = Version{Major: v.Major, Minor: v.Minor + 1}
+	}
+	switch op {
+	case ">=":
+		return []comparator{{">=", v}}, nil
+	case ">":
+		if n < 3 {
+			return []comparator{{">=", next}}, nil
+		}
+		return []comparator{{">", v}}, nil
+	case "<":
+		return []comparator{{"<", v}}, nil
+	case "<=":
+		if n < 3 {
+			return []comparator{{"<", next}}, nil
+		}
+		return []comparator{{"<=", v}}, nil
+	case "~":
+		// ~1.2.3 и ~1.2 фиксируют минорную версию, ~1 — мажорную.
+		upper := Version{Major: v.Major, Minor: v.Minor + 1}
+		if n == 1 {
+			upper = next
+		}
+		return []comparator{{">=", v}, {"<", upper}}, nil
+	case "^":
+		// Фиксируется первая ненулевая компонента: ^1.2 <2.0.0, ^0.2 <0.3.0, ^0.0.3 <0.0.4.
+		var upper Version
+		switch {
+		case v.Major > 0 || n == 1:
+			upper = Version{Major: v.Major + 1}
+		case v.Minor > 0 || n == 2:
+			upper = Version{Minor: v.Minor + 1}
+		default:
+			upper = Version{Patch: v.Patch + 1}
+		}
+		return []comparator{{">=", v}, {"<", upper}}, nil
+	default: // "" и "="
+		if n < 3 {
+			return []comparator{{">=", v}, {"<", next}}, nil
+		}
+		return []comparator{{"=", v}}, nil
+	}
+}
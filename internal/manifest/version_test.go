@@ -0,0 +1,125 @@
+package manifest
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2.3", "1.2.4", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"2.0.0", "10.0.0", -1},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0", "1.0.0-rc.1", 1},
+		{"1.0.0-rc.9", "1.0.0-rc.10", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-alpha.beta", "1.0.0-beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-rc.1", "1.0.0-RC.1", 1},
+		{"1.0.0+build.1", "1.0.0+build.2", 0},
+	}
+	for _, tt := range tests {
+		a, err := ParseVersion(tt.a)
+		if err != nil {
+			t.Fatalf("ParseVersion(%q): %v", tt.a, err)
+		}
+		b, err := ParseVersion(tt.b)
+		if err != nil {
+			t.Fatalf("ParseVersion(%q): %v", tt.b, err)
+		}
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("%s.Compare(%s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := b.Compare(a); got != -tt.want {
+			t.Errorf("%s.Compare(%s) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestMatchVersion(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"*", "0.0.1", true},
+		{"", "3.1.4", true},
+		{"1.2.3", "1.2.3", true},
+		{"=1.2.3", "1.2.4", false},
+		{"1.2", "1.2.9", true},
+		{"1.2", "1.3.0", false},
+		{"=1", "1.9.9", true},
+
+		{">=1.2", "1.2.0", true},
+		{">=1.2", "1.1.9", false},
+		{">1", "1.9.9", false},
+		{">1", "2.0.0", true},
+		{">1.2", "1.2.9", false},
+		{">1.2", "1.3.0", true},
+		{">1.2.3", "1.2.4", true},
+		{"<2", "1.9.9", true},
+		{"<2", "2.0.0", false},
+		{"<=2.0", "2.0.5", true},
+		{"<=2.0", "2.1.0", false},
+		{"<=2", "2.9.0", true},
+		{"<=2.0.0", "2.0.1", false},
+
+		{"^1.2", "1.9.0", true},
+		{"^1.2", "1.1.0", false},
+		{"^1.2", "2.0.0", false},
+		{"^0.2", "0.2.5", true},
+		{"^0.2", "0.9.1", false},
+		{"^0.2.3", "0.2.2", false},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.3", true},
+		{"^0.0.3", "0.0.4", false},
+		{"^0.0", "0.0.9", true},
+		{"^0.0", "0.1.0", false},
+		{"^0", "0.9.0", true},
+		{"^0", "1.0.0", false},
+
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"~1.2", "1.2.0", true},
+		{"~1", "1.9.0", true},
+		{"~1", "2.0.0", false},
+		{"~0.2", "0.3.0", false},
+
+		{">=0.2.0, <1.0.0", "0.9.9", true},
+		{">=0.2.0, <1.0.0", "1.0.0", false},
+
+		{"^1.2", "2.0.0-rc.1", false},
+		{"<2.0.0", "2.0.0-rc.1", false},
+		{"^1.2", "1.5.0-beta", false},
+		{">=2.0.0-rc.2", "2.0.0-rc.10", true},
+		{">=2.0.0-rc.10", "2.0.0-rc.9", false},
+		{"^2.0.0-rc.1", "2.0.0", true},
+		{"^2.0.0-rc.1", "2.1.0-rc.1", false},
+	}
+	for _, tt := range tests {
+		got, err := MatchVersion(tt.constraint, tt.version)
+		if err != nil {
+			t.Errorf("MatchVersion(%q, %q): %v", tt.constraint, tt.version, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("MatchVersion(%q, %q) = %v, want %v", tt.constraint, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestValidateConstraint(t *testing.T) {
+	for _, constraint := range []string{"*", "1", ">=1.2", "^0.2.3", "~1.2, <1.2.5", ">=2.0.0-rc.1"} {
+		if err := ValidateConstraint(constraint); err != nil {
+			t.Errorf("ValidateConstraint(%q): %v", constraint, err)
+		}
+	}
+	for _, constraint := range []string{"abc", ">=1.2.3.4", "^01.2", "~1.2-beta", "=>1.0.0", ">=1.0.0-"} {
+		if err := ValidateConstraint(constraint); err == nil {
+			t.Errorf("ValidateConstraint(%q) succeeded, want an error", constraint)
+		}
+	}
+}
@@ -54,3 +54,32 @@ func ResolveModulesDir(input string) (string, error) {
 	}
 	return abs, nil
 }
+
+// ResolveHubDataDir returns the directory for hub settings and state: input if non-empty,
+// otherwise nekkus/hub under the user config dir (%APPDATA%, ~/Library/Application Support, ~/.config).
+func ResolveHubDataDir(input string) (string, error) {
+	if input != "" {
+		return filepath.Abs(input)
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("hub data dir: %w", err)
+	}
+	return filepath.Join(base, "nekkus", "hub"), nil
+}
+
+// ExpandVars replaces ${name} and $name in s with vars[name]; unknown names are an error.
+func ExpandVars(s string, vars map[string]string) (string, error) {
+	var unknown []string
+	out := os.Expand(s, func(name string) string {
+		value, ok := vars[name]
+		if !ok {
+			unknown = append(unknown, name)
+		}
+		return value
+	})
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("unknown variable ${%s} in %q", unknown[0], s)
+	}
+	return out, nil
+}
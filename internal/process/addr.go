@@ -0,0 +1,93 @@
+package process
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+)
+
+// AutoAddr in grpc_addr asks the hub to pick a free loopback port.
+const AutoAddr = manifest.AutoAddr
+
+// PortAllocation is an entry of the port allocation table; ModuleID is an InstanceKey.
+type PortAllocation struct {
+	ModuleID string `json:"module_id"`
+	Addr     string `json:"addr"`
+	Auto     bool   `json:"auto"`
+}
+
+// allocateAddrLocked reserves the gRPC address for a module instance that is about to start.
+// A fixed grpc_addr belongs to the default instance; named instances always get a free port.
+func (m *Manager) allocateAddrLocked(mod manifest.ModuleManifest, instance string) (string, error) {
+	if mod.GrpcAddr == AutoAddr || instance != "" {
+		key := InstanceKey(mod.ID, instance)
+		for attempt := 0; attempt < 10; attempt++ {
+			addr, err := freeLoopbackAddr()
+			if err != nil {
+				return "", err
+			}
+			if owner := m.addrOwnerLocked(addr); owner == "" {
+				m.addrs[key] = addr
+				return addr, nil
+			}
+		}
+		return "", fmt.Errorf("no free loopback port for %s", key)
+	}
+
+	if owner := m.addrOwnerLocked(mod.GrpcAddr); owner != "" && owner != mod.ID {
+		return "", fmt.Errorf("grpc_addr %s of %s is already allocated to %s", mod.GrpcAddr, mod.ID, owner)
+	}
+	// Занятый чужим процессом порт иначе «пройдёт» waitForTCP и Hub будет говорить не с тем модулем.
+	ln, err := net.Listen("tcp", mod.GrpcAddr)
+	if err != nil {
+		return "", fmt.Errorf("grpc_addr %s of %s is already in use: %w", mod.GrpcAddr, mod.ID, err)
+	}
+	_ = ln.Close()
+	m.addrs[mod.ID] = mod.GrpcAddr
+	return mod.GrpcAddr, nil
+}
+
+func (m *Manager) addrOwnerLocked(addr string) string {
+	for id, a := range m.addrs {
+		if a == addr {
+			return id
+		}
+	}
+	return ""
+}
+
+// Addr returns the gRPC address the running module was launched with.
+func (m *Manager) Addr(moduleID string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	addr, ok := m.addrs[moduleID]
+	return addr, ok
+}
+
+// PortAllocations returns the current port allocation table sorted by module ID.
+func (m *Manager) PortAllocations() []PortAllocation {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	table := make([]PortAllocation, 0, len(m.addrs))
+	for id, addr := range m.addrs {
+		alloc := PortAllocation{ModuleID: id, Addr: addr}
+		if proc := m.processes[id]; proc != nil {
+			alloc.Auto = proc.spec.manifest.GrpcAddr == AutoAddr || proc.spec.instance != ""
+		}
+		table = append(table, alloc)
+	}
+	sort.Slice(table, func(i, j int) bool { return table[i].ModuleID < table[j].ModuleID })
+	return table
+}
+
+func freeLoopbackAddr() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("allocate port: %w", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	return addr, nil
+}
@@ -0,0 +1,221 @@
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+const (
+	stateFileName = "processes.json"
+	// adoptedPollInterval is how often the liveness of an adopted process is checked.
+	adoptedPollInterval = 500 * time.Millisecond
+)
+
+var (
+	errIdentityUnsupported = errors.New("process identity is not available on this platform")
+	// errAdoptedExit is the wait error of an adopted process: it is not a child of the hub,
+	// so its exit status cannot be collected.
+	errAdoptedExit = errors.New("adopted process exited (exit status unknown)")
+)
+
+// processRecord is the persisted description of a running module process.
+type processRecord struct {
+	ModuleID    string    `json:"module_id"`
+	Instance    string    `json:"instance,omitempty"`
+	PID         int       `json:"pid"`
+	StartedAt   time.Time `json:"started_at"`
+	StartTicks  uint64    `json:"start_ticks,omitempty"`
+	Executable  string    `json:"executable"`
+	Addr        string    `json:"addr"`
+	ModulesDir  string    `json:"modules_dir"`
+	HubAddr     string    `json:"hub_addr"`
+	ShowUI      bool      `json:"show_ui,omitempty"`
+	AutoConnect bool      `json:"auto_connect,omitempty"`
+}
+
+func newProcessRecord(spec launchSpec, cmd *exec.Cmd) processRecord {
+	rec := processRecord{
+		ModuleID:    spec.manifest.ID,
+		Instance:    spec.instance,
+		PID:         cmd.Process.Pid,
+		StartedAt:   time.Now(),
+		Executable:  cmd.Path,
+		Addr:        spec.addr,
+		ModulesDir:  spec.modulesDir,
+		HubAddr:     spec.hubAddr,
+		ShowUI:      spec.showUI,
+		AutoConnect: spec.autoConnect,
+	}
+	if abs, err := filepath.Abs(cmd.Path); err == nil {
+		rec.Executable = abs
+	}
+	if _, ticks, err := processIdentity(rec.PID); err == nil {
+		rec.StartTicks = ticks
+	}
+	return rec
+}
+
+// writeStateFile atomically replaces path with v as indented JSON.
+func writeStateFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// AdoptProcesses re-attaches module processes recorded by a previous hub that are still
+// running (see --detach-modules), so that Stop, status, health and logs work for them again.
+// A record is adopted only if its process is alive and is still the recorded program: on
+// Linux the executable and start time must match, elsewhere the module at the recorded
+// address must answer GetInfo with its ID. Output written while no hub was running is not
+// captured. It returns the instance keys of adopted modules.
+func (m *Manager) AdoptProcesses() []string {
+	if m.stateDir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(m.stateDir, stateFileName))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("read process state: %v", err)
+		}
+		return nil
+	}
+	var records []processRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		log.Printf("read process state: %v", err)
+		return nil
+	}
+
+	var adopted []string
+	for _, rec := range records {
+		key := InstanceKey(rec.ModuleID, rec.Instance)
+		if err := m.adopt(rec); err != nil {
+			log.Printf("module %s (pid %d) not adopted: %v", key, rec.PID, err)
+			continue
+		}
+		log.Printf("module %s (pid %d) adopted", key, rec.PID)
+		adopted = append(adopted, key)
+	}
+
+	m.mu.Lock()
+	m.saveStateLocked()
+	m.mu.Unlock()
+	return adopted
+}
+
+func (m *Manager) adopt(rec processRecord) error {
+	mod, ok := m.source.GetManifest(rec.ModuleID)
+	if !ok {
+		return fmt.Errorf("module is not installed")
+	}
+	if err := verifyProcess(rec); err != nil {
+		return err
+	}
+	process, err := os.FindProcess(rec.PID)
+	if err != nil {
+		return err
+	}
+	cmd := &exec.Cmd{Path: rec.Executable, Process: process}
+
+	spec := launchSpec{
+		manifest:    mod,
+		instance:    rec.Instance,
+		addr:        rec.Addr,
+		modulesDir:  rec.ModulesDir,
+		hubAddr:     rec.HubAddr,
+		showUI:      rec.ShowUI,
+		autoConnect: rec.AutoConnect,
+	}
+	proc := &moduleProcess{
+		spec:    spec,
+		cmd:     cmd,
+		record:  rec,
+		running: true,
+		stopCh:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	if m.closing {
+		m.mu.Unlock()
+		return fmt.Errorf("hub is shutting down")
+	}
+	key := spec.key()
+	if m.processes[key] != nil {
+		m.mu.Unlock()
+		return fmt.Errorf("module is already running")
+	}
+	if owner := m.addrOwnerLocked(rec.Addr); owner != "" {
+		m.mu.Unlock()
+		return fmt.Errorf("address %s is allocated to %s", rec.Addr, owner)
+	}
+	if err := m.validateLaunchLocked(mod, rec.Instance); err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	logDir := spec.logDir()
+	moduleLog := m.moduleLogLocked(key)
+	moduleLog.setDir(logDir)
+	proc.watch = watchProcess(rec.PID)
+	proc.watch.output = captureOutput(moduleLog, logDir, true, proc.watch.done)
+	m.addrs[key] = rec.Addr
+	m.processes[key] = proc
+	m.setStateLocked(key, StateReady, "")
+	m.mu.Unlock()
+
+	go m.supervise(proc)
+	go m.healthLoop(proc, proc.watch)
+	go m.metricsLoop(proc, proc.watch, rec.PID)
+	return nil
+}
+
+// verifyProcess checks that the recorded PID still belongs to the recorded module process.
+func verifyProcess(rec processRecord) error {
+	if rec.PID <= 0 || !processAlive(rec.PID) {
+		return fmt.Errorf("process is not running")
+	}
+	exe, ticks, err := processIdentity(rec.PID)
+	if errors.Is(err, errIdentityUnsupported) {
+		return probeModuleID(rec.Addr, rec.ModuleID)
+	}
+	if err != nil {
+		return err
+	}
+	if rec.StartTicks != 0 && ticks != rec.StartTicks {
+		return fmt.Errorf("pid was reused by another process")
+	}
+	want := rec.Executable
+	if resolved, err := filepath.EvalSymlinks(want); err == nil {
+		want = resolved
+	}
+	if exe != want {
+		return fmt.Errorf("pid runs %s, not %s", exe, rec.Executable)
+	}
+	return nil
+}
+
+// watchProcess observes the exit of a process that is not a child of the hub by polling it.
+func watchProcess(pid int) *exitWatch {
+	w := &exitWatch{done: make(chan struct{}), err: errAdoptedExit}
+	go func() {
+		for processAlive(pid) {
+			time.Sleep(adoptedPollInterval)
+		}
+		close(w.done)
+	}()
+	return w
+}
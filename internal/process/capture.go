@@ -0,0 +1,107 @@
+package process
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// capturePollInterval is how often the hub checks capture files for new output.
+const capturePollInterval = 200 * time.Millisecond
+
+// Модуль пишет stdout/stderr не в pipe, а в файлы logs/<stream>.capture: после выхода hub
+// (--detach-modules) запись в pipe убила бы модуль SIGPIPE, а файл новый hub может дочитывать.
+
+func captureFilePath(logDir, stream string) string {
+	return filepath.Join(logDir, stream+".capture")
+}
+
+// openCaptureFiles truncates and opens the stdout and stderr capture files for a new process.
+func openCaptureFiles(logDir string) (stdout, stderr *os.File, err error) {
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create log dir: %w", err)
+	}
+	open := func(stream string) (*os.File, error) {
+		return os.OpenFile(captureFilePath(logDir, stream), os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0o644)
+	}
+	if stdout, err = open(StreamStdout); err != nil {
+		return nil, nil, err
+	}
+	if stderr, err = open(StreamStderr); err != nil {
+		_ = stdout.Close()
+		return nil, nil, err
+	}
+	return stdout, stderr, nil
+}
+
+// captureOutput follows both capture files into l until exited is closed and returns a
+// channel closed once the remaining output has been read. fromEnd skips output written
+// before the call (used for adopted processes whose earlier output is already in module.log).
+func captureOutput(l *moduleLog, logDir string, fromEnd bool, exited <-chan struct{}) <-chan struct{} {
+	done := make(chan struct{})
+	streams := []string{StreamStdout, StreamStderr}
+	finished := make(chan struct{}, len(streams))
+	for _, stream := range streams {
+		go func(stream string) {
+			tailCapture(l, captureFilePath(logDir, stream), stream, fromEnd, exited)
+			finished <- struct{}{}
+		}(stream)
+	}
+	go func() {
+		for range streams {
+			<-finished
+		}
+		close(done)
+	}()
+	return done
+}
+
+func tailCapture(l *moduleLog, path, stream string, fromEnd bool, exited <-chan struct{}) {
+	// Запись нужна для releaseCaptured.
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		log.Printf("capture %s: %v", path, err)
+		return
+	}
+	defer f.Close()
+	if fromEnd {
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			log.Printf("capture %s: %v", path, err)
+			return
+		}
+	}
+
+	w := &logWriter{log: l, stream: stream}
+	defer w.flush()
+	buf := make([]byte, 32<<10)
+	draining := false
+	var released int64
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			_, _ = w.Write(buf[:n])
+			continue
+		}
+		if err != nil && err != io.EOF {
+			log.Printf("capture %s: %v", path, err)
+			return
+		}
+		if draining {
+			return
+		}
+		// Всё прочитанное уже в module.log; освобождаем место, не трогая непрочитанное.
+		if pos, _ := f.Seek(0, io.SeekCurrent); pos-released >= logFileMaxBytes {
+			if next, err := releaseCaptured(f, pos); err == nil {
+				released = next
+			}
+		}
+		select {
+		case <-exited:
+			draining = true
+		case <-time.After(capturePollInterval):
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package process
+
+import (
+	"os"
+	"syscall"
+)
+
+// fallocate(2) modes.
+const (
+	fallocKeepSize  = 0x01
+	fallocPunchHole = 0x02
+)
+
+// releaseCaptured frees the disk space of the first n bytes of a capture file, which are
+// already in module.log, by punching a hole: the file keeps its size and read offset, so
+// output the module appends meanwhile is not lost. It returns the read offset to continue
+// from, which is unchanged.
+func releaseCaptured(f *os.File, n int64) (int64, error) {
+	return n, syscall.Fallocate(int(f.Fd()), fallocPunchHole|fallocKeepSize, 0, n)
+}
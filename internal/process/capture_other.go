@@ -0,0 +1,30 @@
+//go:build !linux
+
+package process
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+var errUnreadCapture = errors.New("capture file has unread output")
+
+// releaseCaptured empties a capture file once all of it (n bytes) is in module.log: without
+// hole punching the file is truncated. The module writes in append mode, so its next output
+// lands at the new start; output written between the size check and the truncation is lost,
+// which is why this is done only after logFileMaxBytes of output. It returns the read offset
+// to continue from.
+func releaseCaptured(f *os.File, n int64) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return n, err
+	}
+	if info.Size() != n {
+		return n, errUnreadCapture
+	}
+	if err := f.Truncate(0); err != nil {
+		return n, err
+	}
+	return f.Seek(0, io.SeekStart)
+}
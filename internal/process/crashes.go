@@ -0,0 +1,98 @@
+package process
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	crashesDirName    = "crashes"
+	crashHistoryLimit = 20
+	crashStderrLines  = 40
+)
+
+// CrashReport describes an exit of a module that the hub did not request.
+type CrashReport struct {
+	ExitInfo
+	Reason    string    `json:"reason"`
+	StartedAt time.Time `json:"started_at"`
+	// RuntimeSeconds is how long the process ran before it exited.
+	RuntimeSeconds float64  `json:"runtime_seconds"`
+	StderrTail     []string `json:"stderr_tail,omitempty"`
+}
+
+// Crashes returns the crash history of a module, oldest first. The history survives hub
+// restarts when the manager has a state directory.
+func (m *Manager) Crashes(moduleID string) []CrashReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]CrashReport{}, m.crashesLocked(moduleID)...)
+}
+
+// LastCrash returns the most recent crash of a module.
+func (m *Manager) LastCrash(moduleID string) (CrashReport, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	crashes := m.crashesLocked(moduleID)
+	if len(crashes) == 0 {
+		return CrashReport{}, false
+	}
+	return crashes[len(crashes)-1], true
+}
+
+// crashesLocked returns the history of a module, loading it from disk on first use.
+func (m *Manager) crashesLocked(moduleID string) []CrashReport {
+	if crashes, ok := m.crashes[moduleID]; ok {
+		return crashes
+	}
+	var crashes []CrashReport
+	if path := m.crashFile(moduleID); path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			if err := json.Unmarshal(data, &crashes); err != nil {
+				log.Printf("read crash history of %s: %v", moduleID, err)
+			}
+		} else if !os.IsNotExist(err) {
+			log.Printf("read crash history of %s: %v", moduleID, err)
+		}
+	}
+	m.crashes[moduleID] = crashes
+	return crashes
+}
+
+// recordCrashLocked appends an unexpected exit of proc with the stderr it printed during
+// the run. The output is fully drained by the time supervise calls markExited.
+func (m *Manager) recordCrashLocked(proc *moduleProcess, exit ExitInfo) {
+	id := proc.spec.key()
+	report := CrashReport{
+		ExitInfo:  exit,
+		Reason:    exit.String(),
+		StartedAt: proc.record.StartedAt,
+	}
+	if !report.StartedAt.IsZero() {
+		report.RuntimeSeconds = exit.ExitedAt.Sub(report.StartedAt).Seconds()
+	}
+	lines := m.moduleLogLocked(id).snapshot(LogQuery{Tail: crashStderrLines, Since: report.StartedAt, Stream: StreamStderr})
+	for _, line := range lines {
+		report.StderrTail = append(report.StderrTail, line.Text)
+	}
+
+	crashes := append(m.crashesLocked(id), report)
+	if len(crashes) > crashHistoryLimit {
+		crashes = crashes[len(crashes)-crashHistoryLimit:]
+	}
+	m.crashes[id] = crashes
+	m.saveCrashesLocked(id)
+}
+
+func (m *Manager) crashFile(moduleID string) string {
+	if m.stateDir == "" {
+		return ""
+	}
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(moduleID)
+	return filepath.Join(m.stateDir, crashesDirName, name+".json")
+}
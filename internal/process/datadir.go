@@ -0,0 +1,106 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+	"github.com/GalitskyKK/nekkus-hub/internal/pathutil"
+)
+
+const defaultStoragePath = "data"
+
+// ResolveDataDir returns the directory passed to the module as --data-dir according to
+// config.data_dir (see manifest.DataDirPolicy). Paths may use the variables of search_paths
+// and ${home}, ${userConfigDir}, ${dataHome}.
+func ResolveDataDir(mod manifest.ModuleManifest, modulesDir string) (string, error) {
+	var policy manifest.DataDirPolicy
+	storagePath := defaultStoragePath
+	if mod.Config != nil {
+		if mod.Config.StoragePath != "" {
+			storagePath = mod.Config.StoragePath
+		}
+		if mod.Config.DataDir != nil {
+			policy = *mod.Config.DataDir
+		}
+	}
+
+	vars := dataDirVars(modulesDir, mod.ID)
+	path, err := pathutil.ExpandVars(policy.Path, vars)
+	if err != nil {
+		return "", fmt.Errorf("data_dir of %s: %w", mod.ID, err)
+	}
+
+	var base string
+	switch policy.Mode {
+	case "", manifest.DataDirModule:
+		base = vars["moduleDir"]
+		if path == "" {
+			path = storagePath
+		}
+	case manifest.DataDirSharedUserConfig, manifest.DataDirXDGData:
+		key := "userConfigDir"
+		if policy.Mode == manifest.DataDirXDGData {
+			key = "dataHome"
+		}
+		base = vars[key]
+		if base == "" {
+			return "", fmt.Errorf("data_dir of %s: %s is not available", mod.ID, policy.Mode)
+		}
+		if path == "" {
+			path = filepath.Join("nekkus", mod.ID)
+		}
+	case manifest.DataDirCustom:
+		if path == "" {
+			return "", fmt.Errorf("data_dir of %s: custom mode requires a path", mod.ID)
+		}
+		if !filepath.IsAbs(path) {
+			return "", fmt.Errorf("data_dir of %s: custom path %q must be absolute", mod.ID, path)
+		}
+		return filepath.Clean(path), nil
+	default:
+		return "", fmt.Errorf("data_dir of %s: unknown mode %q", mod.ID, policy.Mode)
+	}
+
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("data_dir of %s: path %q must be relative in %s mode", mod.ID, path, policy.Mode)
+	}
+	return filepath.Join(base, path), nil
+}
+
+// dataDirVars extends pathVars with user directories; unavailable ones are left out.
+func dataDirVars(modulesDir, moduleID string) map[string]string {
+	vars := pathVars(modulesDir, moduleID)
+	if home, err := os.UserHomeDir(); err == nil {
+		vars["home"] = home
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		vars["userConfigDir"] = dir
+	}
+	if dir := dataHomeDir(); dir != "" {
+		vars["dataHome"] = dir
+	}
+	return vars
+}
+
+// dataHomeDir returns $XDG_DATA_HOME or its platform equivalent.
+func dataHomeDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return dir
+	}
+	switch runtime.GOOS {
+	case "windows":
+		return os.Getenv("LOCALAPPDATA")
+	case "darwin":
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, "Library", "Application Support")
+		}
+	default:
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, ".local", "share")
+		}
+	}
+	return ""
+}
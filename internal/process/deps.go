@@ -0,0 +1,121 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+)
+
+func (m *Manager) lookupManifest(id string) (manifest.ModuleManifest, bool) {
+	if m.source == nil {
+		return manifest.ModuleManifest{}, false
+	}
+	return m.source.GetManifest(id)
+}
+
+// dependencyOrder returns mod's transitive dependencies followed by mod itself.
+func (m *Manager) dependencyOrder(mod manifest.ModuleManifest) ([]manifest.ModuleManifest, error) {
+	order, err := manifest.DependencyOrder(mod, m.lookupManifest)
+	if err != nil {
+		return nil, fmt.Errorf("resolve dependencies of %s: %w", mod.ID, err)
+	}
+	return order, nil
+}
+
+// runningManifests returns manifests of managed default instances keyed by module ID.
+func (m *Manager) runningManifests() map[string]manifest.ModuleManifest {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	mods := make(map[string]manifest.ModuleManifest, len(m.processes))
+	for id, proc := range m.processes {
+		if !proc.stopping && proc.spec.instance == "" {
+			mods[id] = proc.spec.manifest
+		}
+	}
+	return mods
+}
+
+// RunningDependents returns managed modules that depend on id directly or transitively,
+// ordered so that every module comes before the modules it depends on.
+func (m *Manager) RunningDependents(id string) []manifest.ModuleManifest {
+	running := m.runningManifests()
+	list := make([]manifest.ModuleManifest, 0, len(running))
+	for _, mod := range running {
+		list = append(list, mod)
+	}
+
+	var (
+		order   []manifest.ModuleManifest
+		visited = map[string]bool{id: true}
+		visit   func(target string)
+	)
+	visit = func(target string) {
+		for _, depID := range manifest.Dependents(target, list) {
+			if visited[depID] {
+				continue
+			}
+			visited[depID] = true
+			visit(depID)
+			order = append(order, running[depID])
+		}
+	}
+	visit(id)
+
+	// visit добавляет модуль после его зависимых, этот порядок и нужен для остановки.
+	return order
+}
+
+// StopModuleCascade stops running dependents of mod first and then mod itself.
+func (m *Manager) StopModuleCascade(ctx context.Context, mod manifest.ModuleManifest) (map[string]StopResult, error) {
+	results := make(map[string]StopResult)
+	for _, dep := range m.RunningDependents(mod.ID) {
+		result, err := m.StopModule(ctx, dep)
+		results[dep.ID] = result
+		if err != nil {
+			return results, fmt.Errorf("stop dependent %s of %s: %w", dep.ID, mod.ID, err)
+		}
+	}
+	result, err := m.StopModule(ctx, mod)
+	results[mod.ID] = result
+	return results, err
+}
+
+// warnRunningDependents logs and returns IDs of running modules left without mod.
+func (m *Manager) warnRunningDependents(mod manifest.ModuleManifest) []string {
+	deps := m.RunningDependents(mod.ID)
+	if len(deps) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(deps))
+	for _, dep := range deps {
+		ids = append(ids, dep.ID)
+	}
+	log.Printf("module %s is stopped while dependents are running: %v", mod.ID, ids)
+	return ids
+}
+
+// waitForDependents blocks until every instance in specs of a direct dependent of id has
+// been stopped (stopped is keyed by InstanceKey), or ctx is done.
+func waitForDependents(ctx context.Context, id string, specs []launchSpec, stopped map[string]chan struct{}) {
+	mods := make([]manifest.ModuleManifest, 0, len(specs))
+	for _, spec := range specs {
+		mods = append(mods, spec.manifest)
+	}
+	dependents := make(map[string]bool)
+	for _, depID := range manifest.Dependents(id, mods) {
+		dependents[depID] = true
+	}
+	for _, spec := range specs {
+		ch, ok := stopped[spec.key()]
+		if !ok || !dependents[spec.manifest.ID] {
+			continue
+		}
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+)
+
+// Environment modes of module processes (hub setting env_mode).
+const (
+	// EnvInherit passes the whole hub environment except WAILS*/VITE* variables.
+	EnvInherit = "inherit"
+	// EnvIsolated passes only defaultEnvAllowlist and the manifest env_passthrough.
+	EnvIsolated = "isolated"
+)
+
+// defaultEnvAllowlist is what a module needs to run and to open windows; "*" matches a prefix.
+var defaultEnvAllowlist = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TMPDIR", "TZ",
+	"LANG", "LANGUAGE", "LC_*", "XDG_*",
+	"DISPLAY", "WAYLAND_DISPLAY", "DBUS_SESSION_BUS_ADDRESS",
+	// Windows.
+	"SYSTEMROOT", "SYSTEMDRIVE", "WINDIR", "COMSPEC", "PATHEXT", "TEMP", "TMP",
+	"USERNAME", "USERPROFILE", "HOMEDRIVE", "HOMEPATH", "APPDATA", "LOCALAPPDATA",
+	"PROGRAMDATA", "PROGRAMFILES", "PROGRAMFILES(X86)", "COMMONPROGRAMFILES",
+	"NUMBER_OF_PROCESSORS", "PROCESSOR_ARCHITECTURE", "OS",
+}
+
+// ValidateEnvMode checks an env_mode setting.
+func ValidateEnvMode(mode string) error {
+	switch mode {
+	case EnvInherit, EnvIsolated:
+		return nil
+	default:
+		return fmt.Errorf("unknown env mode %q (expected %q or %q)", mode, EnvInherit, EnvIsolated)
+	}
+}
+
+// SetEnvMode switches how the hub environment is passed to modules launched from now on.
+func (m *Manager) SetEnvMode(mode string) error {
+	if err := ValidateEnvMode(mode); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.envMode = mode
+	m.mu.Unlock()
+	return nil
+}
+
+// EnvMode returns the current env mode.
+func (m *Manager) EnvMode() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.envMode
+}
+
+// inheritedEnv returns the part of the hub environment a module receives in mode.
+func inheritedEnv(mode string, passthrough []string) []string {
+	env := make([]string, 0, len(os.Environ()))
+	for _, item := range os.Environ() {
+		key := strings.SplitN(item, "=", 2)[0]
+		if mode == EnvIsolated {
+			if key == "" || !(envAllowed(key, defaultEnvAllowlist) || envAllowed(key, passthrough)) {
+				continue
+			}
+		} else if strings.HasPrefix(key, "WAILS") || strings.HasPrefix(key, "VITE") {
+			continue
+		}
+		env = append(env, item)
+	}
+	return env
+}
+
+func envAllowed(key string, patterns []string) bool {
+	if runtime.GOOS == "windows" {
+		key = strings.ToUpper(key)
+	}
+	for _, pattern := range patterns {
+		if runtime.GOOS == "windows" {
+			pattern = strings.ToUpper(pattern)
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// Sources of module environment variables in EnvReport.
+const (
+	EnvSourceInherited   = "inherited"   // hub environment in the inherit mode
+	EnvSourceBase        = "base"        // defaultEnvAllowlist in the isolated mode
+	EnvSourcePassthrough = "passthrough" // env_passthrough of the manifest in the isolated mode
+	EnvSourceHub         = "hub"         // NEKKUS_* variables set by the hub
+	EnvSourceManifest    = "manifest"    // env of the manifest
+	EnvSourceOverride    = "override"    // env of the launch overrides
+)
+
+// EnvVar is a variable of a module environment and where its value comes from.
+type EnvVar struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+}
+
+// EnvReport shows the environment of a module for debugging. Values are never reported:
+// the inherited environment may hold the user's tokens and keys.
+type EnvReport struct {
+	Mode string `json:"mode"`
+	// Next are the variables the next background launch would get, sorted by name.
+	Next []EnvVar `json:"next"`
+	// Running are the names of the variables of the running process when this hub launched it.
+	Running []string `json:"running,omitempty"`
+}
+
+// DebugEnv returns the variables a module would be launched with and, if it is running,
+// the names of the variables it was launched with.
+func (m *Manager) DebugEnv(mod manifest.ModuleManifest, modulesDir, hubAddr string) (EnvReport, error) {
+	report := EnvReport{Mode: m.EnvMode()}
+
+	addr, running := m.Addr(mod.ID)
+	if !running {
+		addr = mod.GrpcAddr
+	}
+	dataDir, err := ResolveDataDir(mod, modulesDir)
+	if err != nil {
+		return report, err
+	}
+	spec := launchSpec{
+		manifest:    mod,
+		addr:        addr,
+		modulesDir:  modulesDir,
+		hubAddr:     hubAddr,
+		autoConnect: true,
+	}
+	// Рендерим шаблоны, чтобы отчёт показывал те же ошибки, что и запуск.
+	if _, _, err = m.commandLine(spec, dataDir); err != nil {
+		return report, err
+	}
+
+	// Порядок как в commandLine: более поздний источник переопределяет ранний.
+	sources := make(map[string]string)
+	for _, item := range inheritedEnv(report.Mode, mod.EnvPassthrough) {
+		name := envName(item)
+		switch {
+		case report.Mode != EnvIsolated:
+			sources[name] = EnvSourceInherited
+		case envAllowed(name, defaultEnvAllowlist):
+			sources[name] = EnvSourceBase
+		default:
+			sources[name] = EnvSourcePassthrough
+		}
+	}
+	for _, item := range buildModuleEnv(nil, spec.hubAddr, spec.showUI, spec.autoConnect) {
+		sources[envName(item)] = EnvSourceHub
+	}
+	for name := range mod.Env {
+		sources[name] = EnvSourceManifest
+	}
+	for name := range m.LaunchOverrides(mod.ID).Env {
+		sources[name] = EnvSourceOverride
+	}
+	report.Next = make([]EnvVar, 0, len(sources))
+	for name, source := range sources {
+		report.Next = append(report.Next, EnvVar{Name: name, Source: source})
+	}
+	sort.Slice(report.Next, func(i, j int) bool { return report.Next[i].Name < report.Next[j].Name })
+
+	m.mu.RLock()
+	if proc := m.processes[mod.ID]; proc != nil && proc.cmd != nil {
+		for _, item := range proc.cmd.Env {
+			report.Running = append(report.Running, envName(item))
+		}
+	}
+	m.mu.RUnlock()
+	sort.Strings(report.Running)
+	return report, nil
+}
+
+func envName(item string) string {
+	name, _, _ := strings.Cut(item, "=")
+	return name
+}
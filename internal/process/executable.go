@@ -0,0 +1,64 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+	"github.com/GalitskyKK/nekkus-hub/internal/pathutil"
+)
+
+// pathVars returns the variables available in manifest paths:
+// ${modulesDir}, ${moduleDir}, ${hubDir} (directory of the hub executable), ${GOOS} and ${GOARCH}.
+func pathVars(modulesDir, moduleID string) map[string]string {
+	hubDir := ""
+	if exe, err := os.Executable(); err == nil {
+		hubDir = filepath.Dir(exe)
+	}
+	return map[string]string{
+		"modulesDir": modulesDir,
+		"moduleDir":  filepath.Join(modulesDir, moduleID),
+		"hubDir":     hubDir,
+		"GOOS":       runtime.GOOS,
+		"GOARCH":     runtime.GOARCH,
+	}
+}
+
+// resolveExecutablePath looks for the module executable in its search_paths, in order;
+// without search_paths only the module directory is searched. Relative paths are
+// relative to the module directory.
+func resolveExecutablePath(mod manifest.ModuleManifest, modulesDir string) (string, error) {
+	exeName := mod.ExecutableFor(runtime.GOOS, runtime.GOARCH)
+	if exeName == "" {
+		return "", fmt.Errorf("executable for %s is not set for %s/%s", mod.ID, runtime.GOOS, runtime.GOARCH)
+	}
+	vars := pathVars(modulesDir, mod.ID)
+	exeName, err := pathutil.ExpandVars(exeName, vars)
+	if err != nil {
+		return "", fmt.Errorf("executable of %s: %w", mod.ID, err)
+	}
+
+	searchPaths := mod.SearchPaths
+	if len(searchPaths) == 0 {
+		searchPaths = []string{"${moduleDir}"}
+	}
+	tried := make([]string, 0, len(searchPaths))
+	for _, searchPath := range searchPaths {
+		dir, err := pathutil.ExpandVars(searchPath, vars)
+		if err != nil {
+			return "", fmt.Errorf("search_paths of %s: %w", mod.ID, err)
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(vars["moduleDir"], dir)
+		}
+		candidate := filepath.Join(dir, exeName)
+		if pathutil.FileExists(candidate) {
+			return candidate, nil
+		}
+		tried = append(tried, candidate)
+	}
+	return "", fmt.Errorf("executable not found for %s (tried %s)", mod.ID, strings.Join(tried, ", "))
+}
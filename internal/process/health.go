@@ -0,0 +1,234 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	pb "github.com/GalitskyKK/nekkus-core/pkg/protocol"
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultHealthInterval  = 10 * time.Second
+	defaultHealthTimeout   = 2 * time.Second
+	defaultHealthThreshold = 3
+)
+
+// Health probes reported in HealthStatus.Probe.
+const (
+	ProbeGRPCHealth = "grpc-health"
+	ProbeGetInfo    = "get-info"
+)
+
+// HealthStatus is the result of periodic health checks of a running module.
+type HealthStatus struct {
+	Probe               string    `json:"probe,omitempty"`
+	Healthy             bool      `json:"healthy"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastCheck           time.Time `json:"last_check,omitempty"`
+	LastSuccess         time.Time `json:"last_success,omitempty"`
+	LastError           string    `json:"last_error,omitempty"`
+}
+
+type healthConfig struct {
+	interval         time.Duration
+	timeout          time.Duration
+	threshold        int
+	restartOnFailure bool
+}
+
+func parseHealthConfig(mod manifest.ModuleManifest) (healthConfig, error) {
+	cfg := healthConfig{
+		interval:  defaultHealthInterval,
+		timeout:   defaultHealthTimeout,
+		threshold: defaultHealthThreshold,
+	}
+	h := mod.Health
+	if h == nil {
+		return cfg, nil
+	}
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"health.interval", h.Interval, &cfg.interval},
+		{"health.timeout", h.Timeout, &cfg.timeout},
+	} {
+		if f.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f.value)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("invalid %s %q for %s", f.name, f.value, mod.ID)
+		}
+		*f.dst = d
+	}
+	if h.FailureThreshold < 0 {
+		return cfg, fmt.Errorf("health.failure_threshold must not be negative for %s", mod.ID)
+	}
+	if h.FailureThreshold > 0 {
+		cfg.threshold = h.FailureThreshold
+	}
+	cfg.restartOnFailure = h.RestartOnFailure
+	return cfg, nil
+}
+
+// Health returns the latest health check result of a module.
+func (m *Manager) Health(moduleID string) HealthStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if h := m.health[moduleID]; h != nil {
+		return *h
+	}
+	return HealthStatus{}
+}
+
+// healthLoop probes the module launched as watch until that process exits or is stopped.
+func (m *Manager) healthLoop(proc *moduleProcess, watch *exitWatch) {
+	mod := proc.spec.manifest
+	cfg, err := parseHealthConfig(mod)
+	if err != nil || (mod.Health != nil && mod.Health.Disabled) {
+		return
+	}
+
+	conn, err := grpc.NewClient(proc.spec.addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Printf("module %s: health checks disabled: %v", mod.ID, err)
+		return
+	}
+	defer conn.Close()
+
+	m.mu.Lock()
+	m.health[proc.spec.key()] = &HealthStatus{Healthy: true}
+	m.mu.Unlock()
+
+	useGetInfo := false
+	ticker := time.NewTicker(cfg.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-watch.done:
+			return
+		case <-proc.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		probe := ProbeGRPCHealth
+		if useGetInfo {
+			probe = ProbeGetInfo
+		}
+		err := checkHealth(conn, probe, mod.ID, cfg.timeout)
+		if probe == ProbeGRPCHealth && status.Code(err) == codes.Unimplemented {
+			// Стандартного health-сервиса нет — дальше проверяем через GetInfo.
+			useGetInfo = true
+			probe = ProbeGetInfo
+			err = checkHealth(conn, probe, mod.ID, cfg.timeout)
+		}
+		if m.recordHealth(proc, watch, probe, err, cfg) {
+			log.Printf("module %s is unresponsive, restarting: %v", proc.spec.key(), err)
+			m.restartUnhealthy(proc, watch)
+			return
+		}
+	}
+}
+
+// restartUnhealthy replaces the unresponsive process launched as watch with a new one by a
+// regular stop and start under the operation lock, so the restart is not a crash and does
+// not depend on the restart policy. The module is launched as before, with its window if it had one.
+func (m *Manager) restartUnhealthy(proc *moduleProcess, watch *exitWatch) {
+	spec := proc.spec
+	release, err := m.LockModule(m.ctx, spec.key())
+	if err != nil {
+		return
+	}
+	defer release()
+
+	m.mu.RLock()
+	current := !proc.stopping && proc.watch == watch && m.processes[spec.key()] == proc
+	m.mu.RUnlock()
+	// Пока ждали блокировку, модуль остановили, перезапустили или он упал сам.
+	if !current {
+		return
+	}
+	if _, err := m.stopModule(m.ctx, spec.manifest, spec.instance, false); err != nil {
+		log.Printf("module %s: restart after failed health checks: %v", spec.key(), err)
+		return
+	}
+	if err := m.startModule(m.ctx, spec.manifest, spec.instance, spec.modulesDir, spec.hubAddr, spec.showUI, spec.autoConnect); err != nil {
+		log.Printf("module %s: restart after failed health checks: %v", spec.key(), err)
+	}
+}
+
+// recordHealth updates health and lifecycle state; it returns true when the module must be restarted.
+func (m *Manager) recordHealth(proc *moduleProcess, watch *exitWatch, probe string, err error, cfg healthConfig) bool {
+	id := proc.spec.key()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if proc.stopping || proc.watch != watch || m.processes[id] != proc {
+		return false
+	}
+
+	h := m.health[id]
+	if h == nil {
+		h = &HealthStatus{}
+		m.health[id] = h
+	}
+	now := time.Now()
+	h.Probe = probe
+	h.LastCheck = now
+	if err == nil {
+		h.Healthy = true
+		h.ConsecutiveFailures = 0
+		h.LastSuccess = now
+		h.LastError = ""
+		if m.stateLocked(id) == StateUnhealthy {
+			m.setStateLocked(id, StateReady, "")
+		}
+		return false
+	}
+
+	h.ConsecutiveFailures++
+	h.LastError = err.Error()
+	if h.ConsecutiveFailures < cfg.threshold {
+		return false
+	}
+	h.Healthy = false
+	if m.stateLocked(id) == StateReady {
+		m.setStateLocked(id, StateUnhealthy, fmt.Sprintf("%d failed health checks: %v", h.ConsecutiveFailures, err))
+	}
+	return cfg.restartOnFailure
+}
+
+func checkHealth(conn *grpc.ClientConn, probe, moduleID string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if probe == ProbeGRPCHealth {
+		resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			return err
+		}
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("health status %s", resp.GetStatus())
+		}
+		return nil
+	}
+
+	info, err := pb.NewNekkusModuleClient(conn).GetInfo(ctx, &pb.Empty{})
+	if err != nil {
+		return err
+	}
+	if info.GetId() != moduleID {
+		return fmt.Errorf("address is served by %q, not %s", info.GetId(), moduleID)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+)
+
+const (
+	// instanceSeparator joins the module ID and the instance name in an instance key.
+	instanceSeparator = "@"
+	instancesDirName  = "instances"
+	maxInstanceName   = 64
+)
+
+// InstanceKey identifies a module instance in the Manager: the module ID for the default
+// instance (empty name) and "<module id>@<instance>" for a named one. Read methods that
+// take a moduleID (Status, Health, Logs, Metrics, Crashes, Addr, IsRunning) accept it.
+func InstanceKey(moduleID, instance string) string {
+	if instance == "" {
+		return moduleID
+	}
+	return moduleID + instanceSeparator + instance
+}
+
+// SplitInstanceKey is the inverse of InstanceKey: it returns the module ID and the instance
+// name ("" for the default instance) of key.
+func SplitInstanceKey(key string) (moduleID, instance string) {
+	moduleID, instance, _ = strings.Cut(key, instanceSeparator)
+	return moduleID, instance
+}
+
+// ValidateInstanceName checks the name of a named instance: letters, digits, '-' and '_'.
+func ValidateInstanceName(name string) error {
+	if name == "" || len(name) > maxInstanceName {
+		return fmt.Errorf("instance name must be 1-%d characters", maxInstanceName)
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("invalid instance name %q: only letters, digits, '-' and '_' are allowed", name)
+		}
+	}
+	return nil
+}
+
+// InstanceStatus is a running named instance of a module.
+type InstanceStatus struct {
+	Instance string       `json:"instance"`
+	Running  bool         `json:"running"`
+	Addr     string       `json:"addr,omitempty"`
+	Status   ModuleStatus `json:"status"`
+}
+
+func (s launchSpec) key() string {
+	return InstanceKey(s.manifest.ID, s.instance)
+}
+
+// logDir is where the output of the instance is captured: logs/ of the module for the
+// default instance and logs/instances/<name> for a named one.
+func (s launchSpec) logDir() string {
+	dir := filepath.Join(s.modulesDir, s.manifest.ID, logDirName)
+	if s.instance == "" {
+		return dir
+	}
+	return filepath.Join(dir, instancesDirName, s.instance)
+}
+
+// dataDir is the --data-dir of the instance: a named instance gets instances/<name> inside
+// the data dir of the module, so instances never share state.
+func (s launchSpec) dataDir() (string, error) {
+	dir, err := ResolveDataDir(s.manifest, s.modulesDir)
+	if err != nil || s.instance == "" {
+		return dir, err
+	}
+	return filepath.Join(dir, instancesDirName, s.instance), nil
+}
+
+// StartInstance starts a named instance of a module (instance "" is the default one, as
+// StartModule). A named instance has its own address (always allocated by the hub, since a
+// fixed grpc_addr belongs to the default instance), data dir, logs and launch overrides.
+// Dependencies are started as default instances.
+func (m *Manager) StartInstance(ctx context.Context, mod manifest.ModuleManifest, instance, modulesDir, hubAddr string, showUI, autoConnect bool) error {
+	if instance != "" {
+		if err := ValidateInstanceName(instance); err != nil {
+			return err
+		}
+	}
+	order, err := m.dependencyOrder(mod)
+	if err != nil {
+		return err
+	}
+	if err := m.startDependencies(ctx, order, modulesDir, hubAddr); err != nil {
+		return err
+	}
+	return m.startExclusive(ctx, mod, instance, modulesDir, hubAddr, showUI, autoConnect)
+}
+
+// StopInstance stops a named instance of a module (instance "" is the default one, as
+// StopModule).
+func (m *Manager) StopInstance(ctx context.Context, mod manifest.ModuleManifest, instance string) (StopResult, error) {
+	if instance == "" {
+		return m.StopModule(ctx, mod)
+	}
+	release, err := m.LockModule(ctx, InstanceKey(mod.ID, instance))
+	if err != nil {
+		return StopResult{}, err
+	}
+	defer release()
+	return m.stopModule(ctx, mod, instance, false)
+}
+
+// Instances returns the named instances of a module known to the manager, sorted by name.
+func (m *Manager) Instances(moduleID string) []InstanceStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var list []InstanceStatus
+	for _, proc := range m.processes {
+		if proc.spec.manifest.ID != moduleID || proc.spec.instance == "" {
+			continue
+		}
+		key := proc.spec.key()
+		list = append(list, InstanceStatus{
+			Instance: proc.spec.instance,
+			Running:  proc.running,
+			Addr:     m.addrs[key],
+			Status:   m.statusLocked(key),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Instance < list[j].Instance })
+	return list
+}
+
+// SetInstanceOverrides sets the launch overrides of a named instance; they apply on top of
+// the overrides of the module from the next launch of the instance.
+func (m *Manager) SetInstanceOverrides(moduleID, instance string, o manifest.LaunchOverrides) error {
+	if err := ValidateInstanceName(instance); err != nil {
+		return err
+	}
+	mod, _ := m.lookupManifest(moduleID)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := InstanceKey(moduleID, instance)
+	templates := mergeLaunchTemplates(mod, mergeLaunchOverrides(m.launchOverrides[moduleID], o))
+	if err := templates.validate(key); err != nil {
+		return err
+	}
+	m.launchOverrides[key] = o
+	return nil
+}
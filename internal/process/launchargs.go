@@ -0,0 +1,165 @@
+package process
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+)
+
+const defaultLogLevel = "info"
+
+// launchTemplateData is available to args and env templates, e.g. {{.DataDir}} or {{.ShowUI}}.
+type launchTemplateData struct {
+	ModuleID    string
+	Instance    string
+	HubAddr     string
+	Addr        string
+	DataDir     string
+	ModuleDir   string
+	ModulesDir  string
+	LogLevel    string
+	ShowUI      bool
+	AutoConnect bool
+	GOOS        string
+	GOARCH      string
+}
+
+// launchTemplates are the args and env templates of a module with user overrides applied.
+type launchTemplates struct {
+	args    []string
+	envKeys []string
+	env     map[string]string
+	level   string
+}
+
+func mergeLaunchTemplates(mod manifest.ModuleManifest, o manifest.LaunchOverrides) launchTemplates {
+	t := launchTemplates{
+		args:  append(append([]string{}, mod.Args...), o.Args...),
+		env:   make(map[string]string, len(mod.Env)+len(o.Env)),
+		level: defaultLogLevel,
+	}
+	for key, value := range mod.Env {
+		t.env[key] = value
+	}
+	for key, value := range o.Env {
+		t.env[key] = value
+	}
+	for key := range t.env {
+		t.envKeys = append(t.envKeys, key)
+	}
+	sort.Strings(t.envKeys)
+	if o.LogLevel != "" {
+		t.level = o.LogLevel
+	}
+	return t
+}
+
+// validate checks env names and renders every template with placeholder data,
+// which catches syntax errors and unknown fields.
+func (t launchTemplates) validate(moduleID string) error {
+	for _, key := range t.envKeys {
+		if key == "" || strings.ContainsAny(key, "=\x00") {
+			return fmt.Errorf("env of %s: invalid variable name %q", moduleID, key)
+		}
+	}
+	_, _, err := t.render(launchTemplateData{ModuleID: moduleID})
+	return err
+}
+
+// render returns the extra arguments and KEY=value environment entries for a launch.
+func (t launchTemplates) render(data launchTemplateData) ([]string, []string, error) {
+	data.LogLevel = t.level
+	data.GOOS = runtime.GOOS
+	data.GOARCH = runtime.GOARCH
+
+	args := make([]string, 0, len(t.args))
+	for _, arg := range t.args {
+		value, err := renderTemplate("arg", arg, data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("args of %s: %w", data.ModuleID, err)
+		}
+		args = append(args, value)
+	}
+	env := make([]string, 0, len(t.envKeys))
+	for _, key := range t.envKeys {
+		value, err := renderTemplate(key, t.env[key], data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("env %s of %s: %w", key, data.ModuleID, err)
+		}
+		env = append(env, key+"="+value)
+	}
+	return args, env, nil
+}
+
+func renderTemplate(name, text string, data launchTemplateData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// SetLaunchOverrides sets the user args/env overrides of a module for its next launches.
+func (m *Manager) SetLaunchOverrides(moduleID string, o manifest.LaunchOverrides) error {
+	mod, _ := m.lookupManifest(moduleID)
+	if err := mergeLaunchTemplates(mod, o).validate(moduleID); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.launchOverrides[moduleID] = o
+	m.mu.Unlock()
+	return nil
+}
+
+// ClearLaunchOverrides drops the user overrides so only the manifest args/env apply.
+func (m *Manager) ClearLaunchOverrides(moduleID string) {
+	m.mu.Lock()
+	delete(m.launchOverrides, moduleID)
+	m.mu.Unlock()
+}
+
+// LaunchOverrides returns the user overrides of a module.
+func (m *Manager) LaunchOverrides(moduleID string) manifest.LaunchOverrides {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.launchOverrides[moduleID]
+}
+
+// launchTemplatesLocked merges the manifest with the overrides of the module and, for a
+// named instance, with the overrides of the instance.
+func (m *Manager) launchTemplatesLocked(mod manifest.ModuleManifest, instance string) launchTemplates {
+	o := m.launchOverrides[mod.ID]
+	if instance != "" {
+		o = mergeLaunchOverrides(o, m.launchOverrides[InstanceKey(mod.ID, instance)])
+	}
+	return mergeLaunchTemplates(mod, o)
+}
+
+// mergeLaunchOverrides applies override on top of base: args are appended, env and the
+// log level replace.
+func mergeLaunchOverrides(base, override manifest.LaunchOverrides) manifest.LaunchOverrides {
+	o := manifest.LaunchOverrides{
+		Args:     append(append([]string{}, base.Args...), override.Args...),
+		Env:      make(map[string]string, len(base.Env)+len(override.Env)),
+		LogLevel: base.LogLevel,
+	}
+	for key, value := range base.Env {
+		o.Env[key] = value
+	}
+	for key, value := range override.Env {
+		o.Env[key] = value
+	}
+	if override.LogLevel != "" {
+		o.LogLevel = override.LogLevel
+	}
+	return o
+}
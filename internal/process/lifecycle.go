@@ -0,0 +1,181 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// State is the lifecycle state of a module.
+type State string
+
+// Module lifecycle states.
+const (
+	StateStopped      State = "stopped"
+	StateStarting     State = "starting"
+	StateReady        State = "ready"
+	StateUnhealthy    State = "unhealthy"
+	StateStopping     State = "stopping"
+	StateCrashed      State = "crashed"
+	StateCrashLooping State = "crash-looping"
+)
+
+const maxTransitions = 20
+
+// ErrInvalidTransition is wrapped by TransitionError; the API maps it to 409 Conflict.
+var ErrInvalidTransition = errors.New("invalid lifecycle transition")
+
+// TransitionError is returned when a requested operation does not fit the current state.
+type TransitionError struct {
+	ModuleID string
+	From     State
+	To       State
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("module %s is %s, cannot move to %s", e.ModuleID, e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// allowedTransitions lists transitions that user requests (start/stop) may cause.
+// Facts observed by the supervisor (exits, readiness) are recorded unconditionally.
+var allowedTransitions = map[State][]State{
+	StateStopped:      {StateStarting},
+	StateStarting:     {},
+	StateReady:        {StateStopping},
+	StateUnhealthy:    {StateStopping},
+	StateCrashed:      {StateStarting, StateStopping, StateStopped},
+	StateCrashLooping: {StateStarting, StateStopped},
+	StateStopping:     {StateStopped},
+}
+
+// Transition is one recorded state change.
+type Transition struct {
+	From  State     `json:"from"`
+	To    State     `json:"to"`
+	At    time.Time `json:"at"`
+	Error string    `json:"error,omitempty"`
+}
+
+// ModuleStatus is the lifecycle snapshot exposed by the API.
+type ModuleStatus struct {
+	State       State        `json:"state"`
+	Since       time.Time    `json:"since"`
+	LastError   string       `json:"last_error,omitempty"`
+	Transitions []Transition `json:"transitions,omitempty"`
+}
+
+type lifecycle struct {
+	state       State
+	since       time.Time
+	lastError   string
+	transitions []Transition
+}
+
+func (m *Manager) stateLocked(moduleID string) State {
+	if lc := m.lifecycles[moduleID]; lc != nil {
+		return lc.state
+	}
+	return StateStopped
+}
+
+// transitionLocked validates and records a transition requested by a user operation.
+func (m *Manager) transitionLocked(moduleID string, to State) error {
+	if err := m.checkTransitionLocked(moduleID, to); err != nil {
+		return err
+	}
+	m.setStateLocked(moduleID, to, "")
+	return nil
+}
+
+// checkTransitionLocked validates a transition without recording it.
+func (m *Manager) checkTransitionLocked(moduleID string, to State) error {
+	from := m.stateLocked(moduleID)
+	for _, allowed := range allowedTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return &TransitionError{ModuleID: moduleID, From: from, To: to}
+}
+
+// CheckStart reports whether starting the module instance key fits its current state by the
+// rules of StartModule; a conflict is a *TransitionError. The API checks it before queuing a
+// start, so the state may still change before the start runs.
+func (m *Manager) CheckStart(key string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	// Запущенный модуль стартует идемпотентно.
+	if proc := m.processes[key]; proc != nil && !proc.stopping {
+		return nil
+	}
+	return m.checkTransitionLocked(key, StateStarting)
+}
+
+// CheckStop is CheckStart for StopModule (and the stop half of RestartModule).
+func (m *Manager) CheckStop(key string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	proc := m.processes[key]
+	switch {
+	case proc == nil:
+		if m.stateLocked(key) == StateStopped {
+			return nil
+		}
+		return m.checkTransitionLocked(key, StateStopped)
+	case proc.stopping:
+		return nil
+	}
+	return m.checkTransitionLocked(key, StateStopping)
+}
+
+// setStateLocked records a state change; errMsg becomes the module's last error when set.
+func (m *Manager) setStateLocked(moduleID string, to State, errMsg string) {
+	lc := m.lifecycles[moduleID]
+	if lc == nil {
+		lc = &lifecycle{state: StateStopped}
+		m.lifecycles[moduleID] = lc
+	}
+	if lc.state == to && errMsg == "" {
+		return
+	}
+	now := time.Now()
+	lc.transitions = append(lc.transitions, Transition{From: lc.state, To: to, At: now, Error: errMsg})
+	if len(lc.transitions) > maxTransitions {
+		lc.transitions = lc.transitions[len(lc.transitions)-maxTransitions:]
+	}
+	lc.state = to
+	lc.since = now
+	if errMsg != "" {
+		lc.lastError = errMsg
+	}
+}
+
+func (m *Manager) setState(moduleID string, to State, errMsg string) {
+	m.mu.Lock()
+	m.setStateLocked(moduleID, to, errMsg)
+	m.mu.Unlock()
+}
+
+// Status returns the lifecycle state of a module with its recent transitions.
+func (m *Manager) Status(moduleID string) ModuleStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.statusLocked(moduleID)
+}
+
+func (m *Manager) statusLocked(moduleID string) ModuleStatus {
+	lc := m.lifecycles[moduleID]
+	if lc == nil {
+		return ModuleStatus{State: StateStopped}
+	}
+	return ModuleStatus{
+		State:       lc.state,
+		Since:       lc.since,
+		LastError:   lc.lastError,
+		Transitions: append([]Transition(nil), lc.transitions...),
+	}
+}
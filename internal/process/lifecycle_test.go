@@ -0,0 +1,68 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitState polls the lifecycle state of key until it is want.
+func waitState(t *testing.T, m *Manager, key string, want State) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for m.Status(key).State != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("state of %s = %s, want %s", key, m.Status(key).State, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStopWhileStartingIsRejected(t *testing.T) {
+	modulesDir := t.TempDir()
+	mod := testModule(t, modulesDir, "com.test.app")
+	m := newTestManager(t, mod)
+
+	// С autoConnect модуль готов только после Register, которого тест пока не делает.
+	started := make(chan error, 1)
+	go func() {
+		started <- m.StartModule(context.Background(), mod, modulesDir, "127.0.0.1:1", false, true)
+	}()
+	waitState(t, m, mod.ID, StateStarting)
+
+	err := m.CheckStop(mod.ID)
+	var transition *TransitionError
+	if !errors.Is(err, ErrInvalidTransition) || !errors.As(err, &transition) {
+		t.Fatalf("CheckStop while starting = %v, want an invalid transition", err)
+	}
+	if transition.From != StateStarting {
+		t.Errorf("transition from %s, want from %s", transition.From, StateStarting)
+	}
+	if err := m.CheckStart(mod.ID); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("CheckStart while starting = %v, want an invalid transition", err)
+	}
+
+	// Регистрация засчитывается, только если пришла после запуска процесса.
+	for done := false; !done; {
+		m.NotifyRegistered(mod.ID, 0)
+		select {
+		case err := <-started:
+			if err != nil {
+				t.Fatalf("StartModule: %v", err)
+			}
+			done = true
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	waitState(t, m, mod.ID, StateReady)
+	if err := m.CheckStop(mod.ID); err != nil {
+		t.Errorf("CheckStop when ready = %v", err)
+	}
+	if _, err := m.StopModule(context.Background(), mod); err != nil {
+		t.Fatalf("StopModule: %v", err)
+	}
+	if err := m.CheckStart(mod.ID); err != nil {
+		t.Errorf("CheckStart when stopped = %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package process
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+)
+
+// cpuPeriodMicros is the cgroup cpu.max period used for cpu_quota.
+const cpuPeriodMicros = 100000
+
+// Limit names reported in EnforcedLimit.Limit (the manifest field names).
+const (
+	LimitMaxMemory    = "max_memory"
+	LimitCPUWeight    = "cpu_weight"
+	LimitCPUQuota     = "cpu_quota"
+	LimitMaxOpenFiles = "max_open_files"
+	LimitMaxProcesses = "max_processes"
+)
+
+// Mechanisms reported in EnforcedLimit.Mechanism.
+const (
+	MechanismCgroup = "cgroup"
+	MechanismRlimit = "rlimit"
+)
+
+// EnforcedLimit reports how one requested limit was applied at the last launch.
+type EnforcedLimit struct {
+	Limit     string `json:"limit"`
+	Value     string `json:"value"`
+	Enforced  bool   `json:"enforced"`
+	Mechanism string `json:"mechanism,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// LimitsStatus is the effective limits of a module and their enforcement at the last launch.
+type LimitsStatus struct {
+	Effective  manifest.ResourceLimits  `json:"effective"`
+	Overridden bool                     `json:"overridden"`
+	Enforced   []EnforcedLimit          `json:"enforced,omitempty"`
+	Manifest   *manifest.ResourceLimits `json:"manifest,omitempty"`
+}
+
+type resourceLimits struct {
+	memoryBytes    int64
+	cpuWeight      int
+	cpuQuotaMicros int64
+	maxOpenFiles   int
+	maxProcesses   int
+}
+
+func (l resourceLimits) empty() bool {
+	return l == resourceLimits{}
+}
+
+// ValidateResourceLimits checks limits coming from a manifest or the API.
+func ValidateResourceLimits(l manifest.ResourceLimits) error {
+	_, err := parseResourceLimits(l)
+	return err
+}
+
+func parseResourceLimits(l manifest.ResourceLimits) (resourceLimits, error) {
+	var r resourceLimits
+	if l.MaxMemory != "" {
+		n, err := parseByteSize(l.MaxMemory)
+		if err != nil || n <= 0 {
+			return r, fmt.Errorf("invalid max_memory %q", l.MaxMemory)
+		}
+		r.memoryBytes = n
+	}
+	if l.CPUWeight != 0 {
+		if l.CPUWeight < 1 || l.CPUWeight > 10000 {
+			return r, fmt.Errorf("cpu_weight must be between 1 and 10000")
+		}
+		r.cpuWeight = l.CPUWeight
+	}
+	if l.CPUQuota != "" {
+		cpus, err := parseCPUQuota(l.CPUQuota)
+		if err != nil {
+			return r, err
+		}
+		r.cpuQuotaMicros = int64(cpus * cpuPeriodMicros)
+		if r.cpuQuotaMicros < 1000 {
+			return r, fmt.Errorf("cpu_quota %q is too small", l.CPUQuota)
+		}
+	}
+	if l.MaxOpenFiles < 0 || l.MaxProcesses < 0 {
+		return r, fmt.Errorf("max_open_files and max_processes must not be negative")
+	}
+	r.maxOpenFiles = l.MaxOpenFiles
+	r.maxProcesses = l.MaxProcesses
+	return r, nil
+}
+
+// parseByteSize parses "1048576", "512K", "512M", "2G" (powers of 1024; a trailing "B"/"iB" is allowed).
+func parseByteSize(s string) (int64, error) {
+	raw := strings.ToUpper(strings.TrimSpace(s))
+	raw = strings.TrimSuffix(strings.TrimSuffix(raw, "B"), "I")
+	multiplier := int64(1)
+	if n := len(raw); n > 0 {
+		switch raw[n-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			raw = raw[:n-1]
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * multiplier, nil
+}
+
+// parseCPUQuota parses "50%" or "1.5" into a number of CPUs.
+func parseCPUQuota(s string) (float64, error) {
+	raw := strings.TrimSpace(s)
+	divisor := 1.0
+	if strings.HasSuffix(raw, "%") {
+		raw = strings.TrimSuffix(raw, "%")
+		divisor = 100
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid cpu_quota %q", s)
+	}
+	return v / divisor, nil
+}
+
+// mergeResourceLimits applies non-zero fields of override on top of base.
+func mergeResourceLimits(base *manifest.ResourceLimits, override manifest.ResourceLimits) manifest.ResourceLimits {
+	var l manifest.ResourceLimits
+	if base != nil {
+		l = *base
+	}
+	if override.MaxMemory != "" {
+		l.MaxMemory = override.MaxMemory
+	}
+	if override.CPUWeight != 0 {
+		l.CPUWeight = override.CPUWeight
+	}
+	if override.CPUQuota != "" {
+		l.CPUQuota = override.CPUQuota
+	}
+	if override.MaxOpenFiles != 0 {
+		l.MaxOpenFiles = override.MaxOpenFiles
+	}
+	if override.MaxProcesses != 0 {
+		l.MaxProcesses = override.MaxProcesses
+	}
+	return l
+}
+
+// requested lists the limits that are set, in a stable order, for reporting.
+func (l resourceLimits) requested() []EnforcedLimit {
+	var out []EnforcedLimit
+	add := func(name string, set bool, value string) {
+		if set {
+			out = append(out, EnforcedLimit{Limit: name, Value: value})
+		}
+	}
+	add(LimitMaxMemory, l.memoryBytes > 0, strconv.FormatInt(l.memoryBytes, 10))
+	add(LimitCPUWeight, l.cpuWeight > 0, strconv.Itoa(l.cpuWeight))
+	add(LimitCPUQuota, l.cpuQuotaMicros > 0, fmt.Sprintf("%d %d", l.cpuQuotaMicros, cpuPeriodMicros))
+	add(LimitMaxOpenFiles, l.maxOpenFiles > 0, strconv.Itoa(l.maxOpenFiles))
+	add(LimitMaxProcesses, l.maxProcesses > 0, strconv.Itoa(l.maxProcesses))
+	return out
+}
+
+// SetResourceLimits sets user limits of a module on top of its manifest limits; they
+// apply from the next launch, to the default instance and every named one.
+func (m *Manager) SetResourceLimits(moduleID string, l manifest.ResourceLimits) error {
+	if err := ValidateResourceLimits(l); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.limitOverrides[moduleID] = l
+	m.mu.Unlock()
+	return nil
+}
+
+// ClearResourceLimits drops the user limits so only the manifest ones apply.
+func (m *Manager) ClearResourceLimits(moduleID string) {
+	m.mu.Lock()
+	delete(m.limitOverrides, moduleID)
+	m.mu.Unlock()
+}
+
+// LimitsStatus returns the effective limits of a module and how they were enforced on one of
+// its instances (instance "" is the default one).
+func (m *Manager) LimitsStatus(mod manifest.ModuleManifest, instance string) LimitsStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	override, overridden := m.limitOverrides[mod.ID]
+	return LimitsStatus{
+		Effective:  mergeResourceLimits(mod.Limits, override),
+		Overridden: overridden,
+		Enforced:   append([]EnforcedLimit(nil), m.enforcedLimits[InstanceKey(mod.ID, instance)]...),
+		Manifest:   mod.Limits,
+	}
+}
+
+func (m *Manager) resourceLimitsLocked(mod manifest.ModuleManifest) (resourceLimits, error) {
+	limits, err := parseResourceLimits(mergeResourceLimits(mod.Limits, m.limitOverrides[mod.ID]))
+	if err != nil {
+		return limits, fmt.Errorf("limits of %s: %w", mod.ID, err)
+	}
+	return limits, nil
+}
+
+// launchLimits are the limits of a module instance being launched.
+type launchLimits struct {
+	limits resourceLimits
+	cgroup *cgroupPlacement
+}
+
+// prepareLimits resolves the limits of a module instance about to start (key is its
+// InstanceKey) and prepares its cgroup, which startCommand starts the process in.
+func (m *Manager) prepareLimits(mod manifest.ModuleManifest, key string) launchLimits {
+	m.mu.RLock()
+	limits, err := m.resourceLimitsLocked(mod)
+	m.mu.RUnlock()
+	if err != nil || limits.empty() {
+		return launchLimits{}
+	}
+	return launchLimits{limits: limits, cgroup: prepareCgroup(key, limits)}
+}
+
+// enforceLimits applies the rest of the limits to a just started module instance and
+// records the outcome.
+func (m *Manager) enforceLimits(key string, pid int, l launchLimits) {
+	var enforced []EnforcedLimit
+	if !l.limits.empty() {
+		enforced = applyResourceLimits(pid, l.limits, l.cgroup)
+	}
+	m.mu.Lock()
+	m.enforcedLimits[key] = enforced
+	m.mu.Unlock()
+}
@@ -0,0 +1,279 @@
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// hubCgroup is the delegated cgroup v2 directory under which module cgroups are created;
+// ready is set once it is usable.
+var hubCgroup struct {
+	once        sync.Once
+	ready       atomic.Bool
+	dir         string
+	controllers map[string]bool
+	err         error
+}
+
+// cgroupPlacement is the cgroup a module process is started in: the cgroup of the module
+// instance with its limits already written.
+type cgroupPlacement struct {
+	dir         string
+	controllers map[string]bool
+	// err says why the cgroup limits cannot be applied.
+	err error
+	// fd is the cgroup directory for CLONE_INTO_CGROUP until the process is started.
+	fd *os.File
+	// placed reports that the process was started inside the cgroup.
+	placed bool
+}
+
+// prepareCgroup creates the cgroup of a module instance (key is its InstanceKey) for limits
+// that need one, before the process starts. It returns nil when no such limit is set.
+func prepareCgroup(key string, limits resourceLimits) *cgroupPlacement {
+	if limits.memoryBytes <= 0 && limits.cpuWeight <= 0 && limits.cpuQuotaMicros <= 0 && limits.maxProcesses <= 0 {
+		return nil
+	}
+	cg := &cgroupPlacement{}
+	cg.dir, cg.controllers, cg.err = moduleCgroup(key)
+	if cg.err != nil {
+		return cg
+	}
+	if cg.err = writeCgroupLimits(cg.dir, cg.controllers, limits); cg.err != nil {
+		return cg
+	}
+	// Без дескриптора процесс переносится в cgroup после старта.
+	cg.fd, _ = os.Open(cg.dir)
+	return cg
+}
+
+// startCommand starts cmd inside the cgroup of cg (clone3 with CLONE_INTO_CGROUP), so the
+// module and the helpers it spawns are limited from their first instruction. Kernels before
+// 5.7 cannot do that; there a copy of cmd is started normally and applyResourceLimits moves
+// it into the cgroup. It returns the started command.
+func startCommand(cmd *exec.Cmd, cg *cgroupPlacement) (*exec.Cmd, error) {
+	if cg == nil || cg.fd == nil {
+		return cmd, cmd.Start()
+	}
+	defer func() {
+		_ = cg.fd.Close()
+		cg.fd = nil
+	}()
+	plain := cmd.SysProcAttr
+	attr := syscall.SysProcAttr{}
+	if plain != nil {
+		attr = *plain
+	}
+	attr.UseCgroupFD = true
+	attr.CgroupFD = int(cg.fd.Fd())
+	cmd.SysProcAttr = &attr
+	err := cmd.Start()
+	if err == nil {
+		cg.placed = true
+		return cmd, nil
+	}
+
+	retry := exec.Command(cmd.Path, cmd.Args[1:]...)
+	retry.Dir = cmd.Dir
+	retry.Env = cmd.Env
+	retry.Stdin = cmd.Stdin
+	retry.Stdout = cmd.Stdout
+	retry.Stderr = cmd.Stderr
+	retry.SysProcAttr = plain
+	return retry, retry.Start()
+}
+
+// removeModuleCgroup removes the cgroup of a module instance after its process exited; a
+// cgroup that still has processes (helpers left behind) stays until they are gone.
+func removeModuleCgroup(key string) {
+	if !hubCgroup.ready.Load() {
+		return
+	}
+	_ = os.Remove(moduleCgroupPath(key))
+}
+
+// applyResourceLimits enforces limits on a just started process. Memory, CPU and process
+// count go to the cgroup of the module instance prepared by prepareCgroup when the hub runs
+// in a delegated cgroup v2 subtree; open files (and memory without a cgroup, as RLIMIT_DATA)
+// use prlimit. A process not started in its cgroup is moved there right after start, so
+// helpers spawned in the first instant may escape the limits.
+func applyResourceLimits(pid int, limits resourceLimits, cg *cgroupPlacement) []EnforcedLimit {
+	report := limits.requested()
+	var (
+		controllers map[string]bool
+		cgroupErr   = errors.New("no cgroup limits requested")
+	)
+	if cg != nil {
+		controllers, cgroupErr = cg.controllers, cg.err
+		if cgroupErr == nil && !cg.placed {
+			if err := os.WriteFile(filepath.Join(cg.dir, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0o644); err != nil {
+				cgroupErr = fmt.Errorf("move process to cgroup: %w", err)
+			}
+		}
+	}
+
+	controllerOf := map[string]string{
+		LimitMaxMemory:    "memory",
+		LimitCPUWeight:    "cpu",
+		LimitCPUQuota:     "cpu",
+		LimitMaxProcesses: "pids",
+	}
+	for i := range report {
+		r := &report[i]
+		if r.Limit == LimitMaxOpenFiles {
+			setRlimitResult(r, prlimit(pid, syscall.RLIMIT_NOFILE, uint64(limits.maxOpenFiles)))
+			continue
+		}
+		controller := controllerOf[r.Limit]
+		switch {
+		case cgroupErr == nil && controllers[controller]:
+			r.Enforced = true
+			r.Mechanism = MechanismCgroup
+		case r.Limit == LimitMaxMemory:
+			// Без cgroup ограничиваем хотя бы сегмент данных (куча Go считается в RLIMIT_DATA).
+			setRlimitResult(r, prlimit(pid, syscall.RLIMIT_DATA, uint64(limits.memoryBytes)))
+		case cgroupErr != nil:
+			r.Reason = cgroupErr.Error()
+		default:
+			r.Reason = fmt.Sprintf("cgroup controller %s is not available", controller)
+		}
+	}
+	return report
+}
+
+func setRlimitResult(r *EnforcedLimit, err error) {
+	if err != nil {
+		r.Reason = err.Error()
+		return
+	}
+	r.Enforced = true
+	r.Mechanism = MechanismRlimit
+}
+
+// prlimit sets both the soft and the hard limit of resource for pid.
+func prlimit(pid int, resource int, value uint64) error {
+	lim := syscall.Rlimit{Cur: value, Max: value}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource), uintptr(unsafe.Pointer(&lim)), 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("prlimit: %w", errno)
+	}
+	return nil
+}
+
+// moduleCgroup returns (creating it if needed) the cgroup of a module and the controllers
+// enabled for it.
+func moduleCgroup(moduleID string) (string, map[string]bool, error) {
+	hubCgroup.once.Do(func() {
+		hubCgroup.dir, hubCgroup.controllers, hubCgroup.err = setupHubCgroup()
+		hubCgroup.ready.Store(hubCgroup.err == nil)
+	})
+	if hubCgroup.err != nil {
+		return "", nil, hubCgroup.err
+	}
+	dir := moduleCgroupPath(moduleID)
+	if err := os.Mkdir(dir, 0o755); err != nil && !errors.Is(err, os.ErrExist) {
+		return "", nil, fmt.Errorf("create cgroup: %w", err)
+	}
+	return dir, hubCgroup.controllers, nil
+}
+
+// moduleCgroupPath is the cgroup directory of a module; the hub cgroup must be set up.
+func moduleCgroupPath(moduleID string) string {
+	return filepath.Join(hubCgroup.dir, "module-"+strings.ReplaceAll(moduleID, "/", "_"))
+}
+
+// setupHubCgroup prepares the cgroup of the hub for module sub-cgroups. cgroup v2 allows
+// controllers for children only in a cgroup without processes, so the hub first moves
+// itself into a "hub" leaf. This works only when the subtree is delegated to the user
+// (e.g. systemd-run --user --scope -p Delegate=yes).
+func setupHubCgroup() (string, map[string]bool, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return "", nil, fmt.Errorf("cgroup v2 is not mounted at %s", cgroupRoot)
+	}
+	rel, err := ownCgroup()
+	if err != nil {
+		return "", nil, err
+	}
+	dir := filepath.Join(cgroupRoot, rel)
+	leaf := filepath.Join(dir, "hub")
+	if err := os.Mkdir(leaf, 0o755); err != nil && !errors.Is(err, os.ErrExist) {
+		return "", nil, fmt.Errorf("cgroup %s is not delegated: %w", dir, err)
+	}
+	if err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte("0"), 0o644); err != nil {
+		return "", nil, fmt.Errorf("cgroup %s is not delegated: %w", dir, err)
+	}
+
+	available, err := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		return "", nil, err
+	}
+	controllers := make(map[string]bool)
+	for _, c := range strings.Fields(string(available)) {
+		if c != "memory" && c != "cpu" && c != "pids" {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+"+c), 0o644); err == nil {
+			controllers[c] = true
+		}
+	}
+	return dir, controllers, nil
+}
+
+// ownCgroup returns the cgroup v2 path of the hub from /proc/self/cgroup.
+func ownCgroup() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if rel, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return rel, nil
+		}
+	}
+	return "", fmt.Errorf("hub is not in a cgroup v2 hierarchy")
+}
+
+// writeCgroupLimits writes every limit of an available controller, resetting unset ones
+// so limits of a previous launch do not linger.
+func writeCgroupLimits(dir string, controllers map[string]bool, limits resourceLimits) error {
+	files := []struct {
+		controller string
+		name       string
+		value      string
+	}{
+		{"memory", "memory.max", cgroupValue(limits.memoryBytes, "max")},
+		{"cpu", "cpu.weight", cgroupValue(int64(limits.cpuWeight), "100")},
+		{"cpu", "cpu.max", fmt.Sprintf("%s %d", cgroupValue(limits.cpuQuotaMicros, "max"), cpuPeriodMicros)},
+		{"pids", "pids.max", cgroupValue(int64(limits.maxProcesses), "max")},
+	}
+	for _, f := range files {
+		if !controllers[f.controller] {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, f.name), []byte(f.value), 0o644); err != nil {
+			return fmt.Errorf("write %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+func cgroupValue(v int64, unset string) string {
+	if v <= 0 {
+		return unset
+	}
+	return strconv.FormatInt(v, 10)
+}
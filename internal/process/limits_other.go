@@ -0,0 +1,27 @@
+//go:build !linux
+
+package process
+
+import "os/exec"
+
+// cgroupPlacement is not used: cgroups exist on Linux only.
+type cgroupPlacement struct{}
+
+func prepareCgroup(key string, limits resourceLimits) *cgroupPlacement {
+	return nil
+}
+
+func startCommand(cmd *exec.Cmd, cg *cgroupPlacement) (*exec.Cmd, error) {
+	return cmd, cmd.Start()
+}
+
+func removeModuleCgroup(key string) {}
+
+// applyResourceLimits reports every limit as not enforced: limits need rlimits and cgroups.
+func applyResourceLimits(pid int, limits resourceLimits, cg *cgroupPlacement) []EnforcedLimit {
+	report := limits.requested()
+	for i := range report {
+		report[i].Reason = "resource limits are enforced on Linux only"
+	}
+	return report
+}
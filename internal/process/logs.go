@@ -0,0 +1,256 @@
+package process
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Log capture limits.
+const (
+	logBufferLines    = 2000
+	logMaxLineBytes   = 64 << 10
+	logFileMaxBytes   = 5 << 20
+	logFileBackups    = 3
+	logSubscriberSize = 256
+	logDirName        = "logs"
+	logFileName       = "module.log"
+)
+
+// Log streams of a module process.
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// LogLine is one captured line of module output.
+type LogLine struct {
+	Seq    uint64    `json:"seq"`
+	Time   time.Time `json:"time"`
+	Stream string    `json:"stream"`
+	Text   string    `json:"text"`
+}
+
+// LogQuery filters lines returned by Manager.Logs; zero fields do not filter.
+type LogQuery struct {
+	Tail     int
+	SinceSeq uint64
+	Since    time.Time
+	Stream   string
+}
+
+func (q LogQuery) match(line LogLine) bool {
+	if q.Stream != "" && q.Stream != line.Stream {
+		return false
+	}
+	if q.SinceSeq != 0 && line.Seq <= q.SinceSeq {
+		return false
+	}
+	return q.Since.IsZero() || line.Time.After(q.Since)
+}
+
+// moduleLog keeps the last logBufferLines lines in memory and mirrors them to a rotated file.
+type moduleLog struct {
+	mu      sync.Mutex
+	ring    []LogLine
+	next    int
+	full    bool
+	lastSeq uint64
+	file    *rotatingFile
+	subs    map[chan LogLine]string
+}
+
+func newModuleLog() *moduleLog {
+	return &moduleLog{
+		ring: make([]LogLine, logBufferLines),
+		subs: make(map[chan LogLine]string),
+	}
+}
+
+// setDir enables the on-disk copy under dir (once; later calls are ignored).
+func (l *moduleLog) setDir(dir string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		l.file = &rotatingFile{path: filepath.Join(dir, logFileName)}
+	}
+}
+
+func (l *moduleLog) append(stream, text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lastSeq++
+	line := LogLine{Seq: l.lastSeq, Time: time.Now(), Stream: stream, Text: text}
+	l.ring[l.next] = line
+	l.next = (l.next + 1) % len(l.ring)
+	if l.next == 0 {
+		l.full = true
+	}
+
+	if l.file != nil {
+		_ = l.file.write(fmt.Sprintf("%s [%s] %s\n", line.Time.Format(time.RFC3339Nano), stream, text))
+	}
+	for ch, filter := range l.subs {
+		if filter != "" && filter != stream {
+			continue
+		}
+		select {
+		case ch <- line:
+		default:
+			// Медленный подписчик теряет строки, но не блокирует модуль.
+		}
+	}
+}
+
+func (l *moduleLog) snapshot(q LogQuery) []LogLine {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.snapshotLocked(q)
+}
+
+func (l *moduleLog) snapshotLocked(q LogQuery) []LogLine {
+	ordered := l.ring[:l.next]
+	if l.full {
+		ordered = append(append([]LogLine{}, l.ring[l.next:]...), l.ring[:l.next]...)
+	}
+	lines := make([]LogLine, 0, len(ordered))
+	for _, line := range ordered {
+		if q.match(line) {
+			lines = append(lines, line)
+		}
+	}
+	if q.Tail > 0 && len(lines) > q.Tail {
+		lines = lines[len(lines)-q.Tail:]
+	}
+	return lines
+}
+
+// subscribe returns the backlog matching q and a channel with subsequent lines of q.Stream.
+func (l *moduleLog) subscribe(q LogQuery) ([]LogLine, <-chan LogLine, func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	backlog := l.snapshotLocked(q)
+	ch := make(chan LogLine, logSubscriberSize)
+	l.subs[ch] = q.Stream
+	cancel := func() {
+		l.mu.Lock()
+		delete(l.subs, ch)
+		l.mu.Unlock()
+	}
+	return backlog, ch, cancel
+}
+
+// logWriter splits process output into lines for a moduleLog; flush emits an unterminated tail.
+type logWriter struct {
+	log     *moduleLog
+	stream  string
+	partial []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(w.partial[:i])
+		w.partial = w.partial[i+1:]
+	}
+	if len(w.partial) >= logMaxLineBytes {
+		w.emit(w.partial)
+		w.partial = nil
+	}
+	return len(p), nil
+}
+
+func (w *logWriter) flush() {
+	if len(w.partial) > 0 {
+		w.emit(w.partial)
+		w.partial = nil
+	}
+}
+
+func (w *logWriter) emit(b []byte) {
+	w.log.append(w.stream, string(bytes.TrimRight(b, "\r")))
+}
+
+// rotatingFile appends to path and rotates it to path.1..path.N when it grows too large.
+type rotatingFile struct {
+	path string
+	f    *os.File
+	size int64
+}
+
+func (r *rotatingFile) write(s string) error {
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	if r.size+int64(len(s)) > logFileMaxBytes && r.size > 0 {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.f.WriteString(s)
+	r.size += int64(n)
+	return err
+}
+
+func (r *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.f = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) rotate() error {
+	_ = r.f.Close()
+	r.f = nil
+	for i := logFileBackups - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	_ = os.Rename(r.path, r.path+".1")
+	return r.open()
+}
+
+func (m *Manager) moduleLog(moduleID string) *moduleLog {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.moduleLogLocked(moduleID)
+}
+
+func (m *Manager) moduleLogLocked(moduleID string) *moduleLog {
+	l := m.logs[moduleID]
+	if l == nil {
+		l = newModuleLog()
+		m.logs[moduleID] = l
+	}
+	return l
+}
+
+// Logs returns captured output of a module filtered by q.
+func (m *Manager) Logs(moduleID string, q LogQuery) []LogLine {
+	return m.moduleLog(moduleID).snapshot(q)
+}
+
+// SubscribeLogs returns the backlog matching q and a channel of new lines; call cancel when done.
+func (m *Manager) SubscribeLogs(moduleID string, q LogQuery) ([]LogLine, <-chan LogLine, func()) {
+	return m.moduleLog(moduleID).subscribe(q)
+}
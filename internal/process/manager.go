@@ -7,222 +7,433 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
-	"strings"
+	"sort"
 	"sync"
 	"time"
 
 	pb "github.com/GalitskyKK/nekkus-core/pkg/protocol"
 	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
-	"github.com/GalitskyKK/nekkus-hub/internal/pathutil"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
+// ManifestSource resolves manifests of other modules (dependencies and dependents).
+type ManifestSource interface {
+	GetManifest(id string) (manifest.ModuleManifest, bool)
+	ListModules() []manifest.ModuleManifest
+}
+
 // Manager manages module process lifecycle.
 type Manager struct {
-	mu        sync.RWMutex
-	processes map[string]*exec.Cmd
+	source          ManifestSource
+	mu              sync.RWMutex
+	processes       map[string]*moduleProcess
+	supervision     map[string]*supervisorState
+	policies        map[string]manifest.RestartPolicy
+	launchOverrides map[string]manifest.LaunchOverrides
+	limitOverrides  map[string]manifest.ResourceLimits
+	enforcedLimits  map[string][]EnforcedLimit
+	logs            map[string]*moduleLog
+	addrs           map[string]string
+	lifecycles      map[string]*lifecycle
+	health          map[string]*HealthStatus
+	metrics         map[string][]MetricsSample
+	crashes         map[string][]CrashReport
+	opLocks         map[string]chan struct{}
+	closing         bool
+	envMode         string
+	stateDir        string
+	onIdle          func(moduleID string)
+
+	// ctx отменяется Shutdown и прерывает запуски, которые ещё ждут готовности модуля.
+	ctx      context.Context
+	cancel   context.CancelFunc
+	launches sync.WaitGroup
+
+	// Отложенная запись файлов состояния, см. statewriter.go.
+	stateKick    chan struct{}
+	stateWriteMu sync.Mutex
+	stateDirty   bool
+	crashesDirty map[string]bool
+
+	registeredAt    map[string]time.Time
+	registerWaiters map[string]chan struct{}
+}
+
+// launchSpec holds everything needed to (re)launch a module process.
+type launchSpec struct {
+	manifest    manifest.ModuleManifest
+	instance    string // empty for the default instance
+	addr        string
+	modulesDir  string
+	hubAddr     string
+	showUI      bool
+	autoConnect bool
+}
+
+// moduleProcess is a supervised module; cmd is replaced on every restart.
+type moduleProcess struct {
+	spec     launchSpec
+	cmd      *exec.Cmd
+	watch    *exitWatch
+	record   processRecord
+	running  bool
+	stopping bool
+	// abandoned: StopModule gave up waiting for the exit; markExited finishes the stop.
+	abandoned bool
+	stopCh    chan struct{}
+	done      chan struct{} // closed when the current cmd has exited
+	exit      *ExitInfo
 }
 
-// NewManager creates a new process Manager.
-func NewManager() *Manager {
-	return &Manager{
-		processes: make(map[string]*exec.Cmd),
+// NewManager creates a new process Manager; source is used to resolve depends_on.
+// Running processes (for AdoptProcesses) and crash reports are recorded in stateDir;
+// empty disables persistence.
+func NewManager(source ManifestSource, stateDir string) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{
+		ctx:             ctx,
+		cancel:          cancel,
+		stateKick:       make(chan struct{}, 1),
+		crashesDirty:    make(map[string]bool),
+		source:          source,
+		stateDir:        stateDir,
+		processes:       make(map[string]*moduleProcess),
+		supervision:     make(map[string]*supervisorState),
+		policies:        make(map[string]manifest.RestartPolicy),
+		launchOverrides: make(map[string]manifest.LaunchOverrides),
+		limitOverrides:  make(map[string]manifest.ResourceLimits),
+		enforcedLimits:  make(map[string][]EnforcedLimit),
+		envMode:         EnvInherit,
+		logs:            make(map[string]*moduleLog),
+		addrs:           make(map[string]string),
+		lifecycles:      make(map[string]*lifecycle),
+		health:          make(map[string]*HealthStatus),
+		metrics:         make(map[string][]MetricsSample),
+		crashes:         make(map[string][]CrashReport),
+		opLocks:         make(map[string]chan struct{}),
+
+		registeredAt:    make(map[string]time.Time),
+		registerWaiters: make(map[string]chan struct{}),
 	}
+	if stateDir != "" {
+		go m.stateWriter()
+	}
+	return m
 }
 
 // IsRunning reports whether the module is currently running.
 func (m *Manager) IsRunning(moduleID string) bool {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	cmd := m.processes[moduleID]
-	if cmd == nil || cmd.Process == nil {
-		return false
+	proc := m.processes[moduleID]
+	return proc != nil && proc.running
+}
+
+// SetOnIdle sets a callback run, in its own goroutine, when no instance of a module is
+// managed any more (the last one stopped or gave up restarting).
+func (m *Manager) SetOnIdle(onIdle func(moduleID string)) {
+	m.mu.Lock()
+	m.onIdle = onIdle
+	m.mu.Unlock()
+}
+
+// IsManaged reports whether the manager tracks the instance key: the module is running,
+// starting or waiting for a supervised restart.
+func (m *Manager) IsManaged(key string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	proc := m.processes[key]
+	return proc != nil && !proc.stopping
+}
+
+// RunningModules returns sorted keys (see InstanceKey) of module instances managed by the
+// hub, named instances included.
+func (m *Manager) RunningModules() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, 0, len(m.processes))
+	for key, proc := range m.processes {
+		if !proc.stopping {
+			keys = append(keys, key)
+		}
 	}
-	return cmd.ProcessState == nil || !cmd.ProcessState.Exited()
+	sort.Strings(keys)
+	return keys
+}
+
+// HasProcesses reports whether any instance of a module has a process managed by the hub.
+func (m *Manager) HasProcesses(moduleID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, proc := range m.processes {
+		if proc.spec.manifest.ID == moduleID {
+			return true
+		}
+	}
+	return false
 }
 
 // StartModule starts the module process; showUI opens standalone UI, autoConnect enables hub connection.
-func (m *Manager) StartModule(manifest manifest.ModuleManifest, modulesDir, hubAddr string, showUI bool, autoConnect bool) error {
+// Dependencies from depends_on are started first (in the background) and must become ready.
+// Operations on a module are serialized (see LockModule) and starting a running module is a
+// no-op. Cancelling ctx aborts a start that is still waiting for readiness.
+func (m *Manager) StartModule(ctx context.Context, manifest manifest.ModuleManifest, modulesDir, hubAddr string, showUI bool, autoConnect bool) error {
+	order, err := m.dependencyOrder(manifest)
+	if err != nil {
+		return err
+	}
+	if err := m.startDependencies(ctx, order, modulesDir, hubAddr); err != nil {
+		return err
+	}
+	return m.startExclusive(ctx, manifest, "", modulesDir, hubAddr, showUI, autoConnect)
+}
+
+// startModule launches one instance of a module; the caller holds its operation lock.
+func (m *Manager) startModule(ctx context.Context, manifest manifest.ModuleManifest, instance, modulesDir, hubAddr string, showUI bool, autoConnect bool) error {
 	if manifest.ID == "" {
 		return fmt.Errorf("module id is required")
 	}
 	if manifest.GrpcAddr == "" {
-		return fmt.Errorf("grpc_addr is required for %s", manifest.ID)
+		return fmt.Errorf("grpc_addr is required for %s (use %q to let the hub choose a port)", manifest.ID, AutoAddr)
 	}
+	key := InstanceKey(manifest.ID, instance)
 
-	m.mu.RLock()
-	if cmd := m.processes[manifest.ID]; cmd != nil && cmd.Process != nil && (cmd.ProcessState == nil || !cmd.ProcessState.Exited()) {
-		m.mu.RUnlock()
+	m.mu.Lock()
+	if m.closing {
+		m.mu.Unlock()
+		return fmt.Errorf("hub is shutting down")
+	}
+	ctx, endLaunch := m.beginLaunchLocked(ctx)
+	defer endLaunch()
+	// Уже запущен или ждёт перезапуска супервизором.
+	if proc := m.processes[key]; proc != nil && !proc.stopping {
+		m.mu.Unlock()
 		return nil
 	}
-	m.mu.RUnlock()
-
-	exePath, err := resolveExecutablePath(manifest, modulesDir, showUI)
+	if err := m.validateLaunchLocked(manifest, instance); err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	if err := m.transitionLocked(key, StateStarting); err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	// Явный Start сбрасывает crash-loop: пользователь берёт модуль под свою ответственность.
+	state := m.supervisorStateLocked(key)
+	state.crashLooping = false
+	state.recentRestarts = nil
+	addr, err := m.allocateAddrLocked(manifest, instance)
 	if err != nil {
+		m.setStateLocked(key, StateStopped, err.Error())
+		m.mu.Unlock()
 		return err
 	}
+	m.mu.Unlock()
 
-	dataDir := resolveModuleDataDir(manifest, modulesDir)
+	spec := launchSpec{
+		manifest:    manifest,
+		instance:    instance,
+		addr:        addr,
+		modulesDir:  modulesDir,
+		hubAddr:     hubAddr,
+		showUI:      showUI,
+		autoConnect: autoConnect,
+	}
+	cmd, watch, err := m.launch(ctx, spec)
+	if err != nil {
+		m.mu.Lock()
+		delete(m.addrs, key)
+		m.setStateLocked(key, StateStopped, err.Error())
+		m.mu.Unlock()
+		return err
+	}
 
-	if err := os.MkdirAll(dataDir, 0o755); err != nil {
-		return fmt.Errorf("failed to create data dir: %w", err)
+	proc := &moduleProcess{
+		spec:    spec,
+		cmd:     cmd,
+		watch:   watch,
+		record:  newProcessRecord(spec, cmd),
+		running: true,
+		stopCh:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	m.mu.Lock()
+	if m.closing {
+		delete(m.addrs, key)
+		m.setStateLocked(key, StateStopped, "hub is shutting down")
+		m.mu.Unlock()
+		_ = killProcessGroup(cmd)
+		<-watch.done
+		return fmt.Errorf("hub is shutting down")
 	}
+	m.processes[key] = proc
+	m.setStateLocked(key, StateReady, "")
+	m.saveStateLocked()
+	m.mu.Unlock()
 
-	cmd := exec.Command(
-		exePath,
-		"--mode=hub",
-		"--hub-addr="+hubAddr,
-		"--addr="+manifest.GrpcAddr,
-		"--data-dir="+dataDir,
-	)
+	go m.supervise(proc)
+	go m.healthLoop(proc, watch)
+	go m.metricsLoop(proc, watch, cmd.Process.Pid)
 
-	moduleDir := filepath.Join(modulesDir, manifest.ID)
-	if stat, statErr := os.Stat(moduleDir); statErr == nil && stat.IsDir() {
-		cmd.Dir = moduleDir
-	} else {
-		cmd.Dir = filepath.Dir(exePath)
+	return nil
+}
+
+// beginLaunchLocked registers a launch that Shutdown waits for; the returned context is also
+// cancelled by Shutdown. The caller checks m.closing and calls the returned func when the
+// launch is over.
+func (m *Manager) beginLaunchLocked(ctx context.Context) (context.Context, func()) {
+	m.launches.Add(1)
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(m.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		m.launches.Done()
 	}
-	cmd.Env = buildModuleEnv(hubAddr, showUI, autoConnect)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+}
 
-	if err := cmd.Start(); err != nil {
+// validateLaunchLocked checks manifest settings that are only interpreted at launch.
+func (m *Manager) validateLaunchLocked(mod manifest.ModuleManifest, instance string) error {
+	if _, err := m.restartPolicyLocked(mod); err != nil {
+		return fmt.Errorf("restart policy for %s: %w", mod.ID, err)
+	}
+	if _, err := stopTimeout(mod); err != nil {
 		return err
 	}
-
-	m.mu.Lock()
-	m.processes[manifest.ID] = cmd
-	m.mu.Unlock()
-
-	if err := waitForTCP(manifest.GrpcAddr, 5*time.Second); err != nil {
-		_ = cmd.Process.Kill()
+	if _, err := readyTimeout(mod); err != nil {
 		return err
 	}
-
-	go func() {
-		_ = cmd.Wait()
-		m.mu.Lock()
-		delete(m.processes, manifest.ID)
-		m.mu.Unlock()
-	}()
-
-	return nil
+	if _, err := parseHealthConfig(mod); err != nil {
+		return err
+	}
+	if _, err := m.resourceLimitsLocked(mod); err != nil {
+		return err
+	}
+	return m.launchTemplatesLocked(mod, instance).validate(mod.ID)
 }
 
-// StopModule stops the module process.
-func (m *Manager) StopModule(manifest manifest.ModuleManifest) error {
-	m.mu.Lock()
-	cmd := m.processes[manifest.ID]
-	if cmd == nil || cmd.Process == nil {
-		m.mu.Unlock()
-		return nil
+// launch starts the module executable and waits until it is ready (see waitReady).
+func (m *Manager) launch(ctx context.Context, spec launchSpec) (*exec.Cmd, *exitWatch, error) {
+	manifest := spec.manifest
+	exePath, err := resolveExecutablePath(manifest, spec.modulesDir)
+	if err != nil {
+		return nil, nil, err
 	}
-	m.mu.Unlock()
 
-	_ = tryDisconnectModule(manifest.GrpcAddr)
-	time.Sleep(500 * time.Millisecond)
+	dataDir, err := spec.dataDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create data dir: %w", err)
+	}
 
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	cmd = m.processes[manifest.ID]
-	if cmd == nil || cmd.Process == nil {
-		return nil
+	moduleDir := filepath.Join(spec.modulesDir, manifest.ID)
+	args, env, err := m.commandLine(spec, dataDir)
+	if err != nil {
+		return nil, nil, err
 	}
-	_ = cmd.Process.Kill()
-	delete(m.processes, manifest.ID)
-	return nil
-}
+	cmd := exec.Command(exePath, args...)
 
-func resolveExecutablePath(manifest manifest.ModuleManifest, modulesDir string, requireRelease bool) (string, error) {
-	if manifest.Executable == nil {
-		return "", fmt.Errorf("executable is not configured for %s", manifest.ID)
+	if stat, statErr := os.Stat(moduleDir); statErr == nil && stat.IsDir() {
+		cmd.Dir = moduleDir
+	} else {
+		cmd.Dir = filepath.Dir(exePath)
 	}
-	exeName := manifest.Executable[runtime.GOOS]
-	if exeName == "" {
-		return "", fmt.Errorf("executable for %s is not set for %s", manifest.ID, runtime.GOOS)
+	cmd.Env = env
+	configureProcessGroup(cmd)
+
+	logDir := spec.logDir()
+	moduleLog := m.moduleLog(spec.key())
+	moduleLog.setDir(logDir)
+	stdout, stderr, err := openCaptureFiles(logDir)
+	if err != nil {
+		return nil, nil, err
 	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 
-	moduleDir := filepath.Join(modulesDir, manifest.ID)
-	candidate := filepath.Join(moduleDir, exeName)
-	if pathutil.FileExists(candidate) {
-		return candidate, nil
+	limits := m.prepareLimits(manifest, spec.key())
+	launchedAt := time.Now()
+	cmd, err = startCommand(cmd, limits.cgroup)
+	// Дескрипторы унаследованы модулем, копия hub больше не нужна.
+	_ = stdout.Close()
+	_ = stderr.Close()
+	if err != nil {
+		removeModuleCgroup(spec.key())
+		return nil, nil, err
 	}
+	watch := watchExit(cmd, spec.key())
+	watch.output = captureOutput(moduleLog, logDir, false, watch.done)
+	m.enforceLimits(spec.key(), cmd.Process.Pid, limits)
 
-	if manifest.ID == "com.nekkus.net" {
-		// nekkus-hub и nekkus-net — соседи в nekkus/; modulesDir = nekkus-hub/modules → ../.. = nekkus
-		repoBase := filepath.Clean(filepath.Join(modulesDir, "..", "..", "nekkus-net"))
-		rootCandidate := filepath.Join(repoBase, exeName)
-		if pathutil.FileExists(rootCandidate) {
-			return rootCandidate, nil
-		}
-		buildCandidate := filepath.Join(repoBase, "build", "bin", exeName)
-		if pathutil.FileExists(buildCandidate) {
-			return buildCandidate, nil
-		}
-		binCandidate := filepath.Join(repoBase, "bin", exeName)
-		if pathutil.FileExists(binCandidate) {
-			return binCandidate, nil
-		}
-		if requireRelease {
-			return "", fmt.Errorf("release build not found for %s; run: cd nekkus-net && go build -o %s ./cmd", manifest.ID, exeName)
+	if err := m.waitReady(ctx, spec, launchedAt, watch); err != nil {
+		_ = killProcessGroup(cmd)
+		<-watch.done
+		if ctx.Err() != nil {
+			return nil, nil, fmt.Errorf("start of %s cancelled: %w", spec.key(), ctx.Err())
 		}
+		return nil, nil, err
 	}
 
-	return "", fmt.Errorf("executable not found for %s", manifest.ID)
-}
-
-// netModuleDataDir возвращает тот же каталог данных, что и nekkus-net при standalone
-// (%APPDATA%/nekkus/net и т.п.), чтобы подписки и серверы были общими.
-func netModuleDataDir() string {
-	var base string
-	switch runtime.GOOS {
-	case "windows":
-		base = os.Getenv("APPDATA")
-	case "darwin":
-		base = filepath.Join(os.Getenv("HOME"), "Library", "Application Support")
-	default:
-		base = filepath.Join(os.Getenv("HOME"), ".config")
-	}
-	dir := filepath.Join(base, "nekkus", "net")
-	_ = os.MkdirAll(dir, 0o755)
-	return dir
+	return cmd, watch, nil
 }
 
-func resolveModuleDataDir(manifest manifest.ModuleManifest, modulesDir string) string {
-	if manifest.ID == "com.nekkus.net" {
-		return netModuleDataDir()
+// commandLine returns the arguments and the environment of a module launch.
+func (m *Manager) commandLine(spec launchSpec, dataDir string) ([]string, []string, error) {
+	mod := spec.manifest
+	m.mu.RLock()
+	templates := m.launchTemplatesLocked(mod, spec.instance)
+	envMode := m.envMode
+	m.mu.RUnlock()
+	extraArgs, extraEnv, err := templates.render(launchTemplateData{
+		ModuleID:    mod.ID,
+		Instance:    spec.instance,
+		HubAddr:     spec.hubAddr,
+		Addr:        spec.addr,
+		DataDir:     dataDir,
+		ModuleDir:   filepath.Join(spec.modulesDir, mod.ID),
+		ModulesDir:  spec.modulesDir,
+		ShowUI:      spec.showUI,
+		AutoConnect: spec.autoConnect,
+	})
+	if err != nil {
+		return nil, nil, err
 	}
-	dataDir := filepath.Join(modulesDir, manifest.ID, "data")
-	if manifest.Config != nil && manifest.Config.StoragePath != "" {
-		dataDir = filepath.Join(modulesDir, manifest.ID, manifest.Config.StoragePath)
+
+	args := []string{
+		"--mode=hub",
+		"--hub-addr=" + spec.hubAddr,
+		"--addr=" + spec.addr,
+		"--data-dir=" + dataDir,
 	}
-	return dataDir
+	// Переменные из manifest и пользовательских настроек идут последними и переопределяют базовые.
+	env := append(buildModuleEnv(inheritedEnv(envMode, mod.EnvPassthrough), spec.hubAddr, spec.showUI, spec.autoConnect), extraEnv...)
+	return append(args, extraArgs...), env, nil
 }
 
-func waitForTCP(addr string, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+// waitForTCP polls addr until it accepts connections, ctx is done or the process exits.
+func waitForTCP(ctx context.Context, addr string, exited <-chan struct{}) error {
+	for {
 		conn, err := net.DialTimeout("tcp", addr, 300*time.Millisecond)
 		if err == nil {
 			_ = conn.Close()
 			return nil
 		}
-		time.Sleep(200 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("grpc not listening at %s", addr)
+		case <-exited:
+			return fmt.Errorf("process exited")
+		case <-time.After(200 * time.Millisecond):
+		}
 	}
-	return fmt.Errorf("grpc not ready at %s", addr)
 }
 
-func buildModuleEnv(hubAddr string, showUI bool, autoConnect bool) []string {
-	env := make([]string, 0, len(os.Environ())+2)
-	for _, item := range os.Environ() {
-		key := strings.SplitN(item, "=", 2)[0]
-		if strings.HasPrefix(key, "WAILS") || strings.HasPrefix(key, "VITE") {
-			continue
-		}
-		env = append(env, item)
-	}
+func buildModuleEnv(inherited []string, hubAddr string, showUI bool, autoConnect bool) []string {
+	env := make([]string, 0, len(inherited)+10)
+	env = append(env, inherited...)
 	env = append(env, "NEKKUS_HUB_ADDR="+hubAddr)
 	if showUI {
 		env = append(env, "NEKKUS_SHOW_UI=1")
@@ -234,11 +445,6 @@ func buildModuleEnv(hubAddr string, showUI bool, autoConnect bool) []string {
 	} else {
 		env = append(env, "NEKKUS_AUTO_CONNECT=0")
 	}
-	if showUI {
-		env = append(env, "NEKKUS_SINGBOX_LOG=file")
-	} else {
-		env = append(env, "NEKKUS_SINGBOX_LOG=none")
-	}
 	env = append(env, "WAILS_ENV=production")
 	env = append(env, "WAILS_DEV_SERVER_URL=")
 	env = append(env, "WAILS_VITE_DEV_SERVER_URL=")
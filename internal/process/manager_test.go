@@ -0,0 +1,217 @@
+package process
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/GalitskyKK/nekkus-core/pkg/protocol"
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+	"google.golang.org/grpc"
+)
+
+// The test binary doubles as a module: started with testModuleEnv set to a module ID it
+// serves GetInfo on --addr and appends a line to the file in testLaunchesEnv.
+const (
+	testModuleEnv   = "NEKKUS_TEST_MODULE"
+	testLaunchesEnv = "NEKKUS_TEST_LAUNCHES"
+)
+
+func TestMain(m *testing.M) {
+	if id := os.Getenv(testModuleEnv); id != "" {
+		os.Exit(runTestModule(id))
+	}
+	os.Exit(m.Run())
+}
+
+type testModuleServer struct {
+	pb.UnimplementedNekkusModuleServer
+	id string
+}
+
+func (s *testModuleServer) GetInfo(context.Context, *pb.Empty) (*pb.ModuleInfo, error) {
+	return &pb.ModuleInfo{Id: s.id, Version: "1.0.0"}, nil
+}
+
+func runTestModule(id string) int {
+	flags := flag.NewFlagSet(id, flag.ContinueOnError)
+	addr := flags.String("addr", "", "")
+	flags.String("mode", "", "")
+	flags.String("hub-addr", "", "")
+	flags.String("data-dir", "", "")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		return 2
+	}
+	if path := os.Getenv(testLaunchesEnv); path != "" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Fprintln(f, os.Getpid())
+		f.Close()
+	}
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	srv := grpc.NewServer()
+	pb.RegisterNekkusModuleServer(srv, &testModuleServer{id: id})
+	if err := srv.Serve(ln); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+type testSource map[string]manifest.ModuleManifest
+
+func (s testSource) GetManifest(id string) (manifest.ModuleManifest, bool) {
+	m, ok := s[id]
+	return m, ok
+}
+
+func (s testSource) ListModules() []manifest.ModuleManifest {
+	mods := make([]manifest.ModuleManifest, 0, len(s))
+	for _, m := range s {
+		mods = append(mods, m)
+	}
+	return mods
+}
+
+// testModule installs the test binary as module id into modulesDir and returns its manifest.
+func testModule(t *testing.T, modulesDir, id string) manifest.ModuleManifest {
+	t.Helper()
+	name := "module"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	moduleDir := filepath.Join(modulesDir, id)
+	if err := os.MkdirAll(moduleDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.Open(self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(filepath.Join(moduleDir, name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return manifest.ModuleManifest{
+		ID:           id,
+		Version:      "1.0.0",
+		GrpcAddr:     AutoAddr,
+		Executable:   map[string]string{runtime.GOOS: name},
+		StopTimeout:  "5s",
+		ReadyTimeout: "10s",
+		Env: map[string]string{
+			testModuleEnv:   id,
+			testLaunchesEnv: filepath.Join(moduleDir, "launches"),
+		},
+	}
+}
+
+// launches returns how many times the module was started.
+func launches(t *testing.T, modulesDir, id string) int {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(modulesDir, id, "launches"))
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "\n")
+}
+
+// newTestManager returns a Manager over mods that is shut down when the test ends.
+func newTestManager(t *testing.T, mods ...manifest.ModuleManifest) *Manager {
+	t.Helper()
+	source := make(testSource, len(mods))
+	for _, mod := range mods {
+		source[mod.ID] = mod
+	}
+	m := NewManager(source, "")
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		if err := m.Shutdown(ctx); err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+	})
+	return m
+}
+
+func TestConcurrentStartLaunchesOnce(t *testing.T) {
+	modulesDir := t.TempDir()
+	mod := testModule(t, modulesDir, "com.test.app")
+	m := newTestManager(t, mod)
+	idle := make(chan string, 1)
+	m.SetOnIdle(func(moduleID string) { idle <- moduleID })
+
+	const starts = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, starts)
+	for range starts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- m.StartModule(context.Background(), mod, modulesDir, "127.0.0.1:1", false, false)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("StartModule: %v", err)
+		}
+	}
+	if n := launches(t, modulesDir, mod.ID); n != 1 {
+		t.Errorf("module launched %d times, want 1", n)
+	}
+	if !m.IsRunning(mod.ID) || m.Status(mod.ID).State != StateReady {
+		t.Errorf("running = %v, state = %s; want a ready module", m.IsRunning(mod.ID), m.Status(mod.ID).State)
+	}
+
+	result, err := m.StopModule(context.Background(), mod)
+	if err != nil || result.Outcome != StopClean {
+		t.Fatalf("StopModule = %+v, %v; want a clean stop", result, err)
+	}
+	if m.IsManaged(mod.ID) || m.Status(mod.ID).State != StateStopped {
+		t.Errorf("state after stop = %s", m.Status(mod.ID).State)
+	}
+	select {
+	case id := <-idle:
+		if id != mod.ID {
+			t.Errorf("idle module = %s, want %s", id, mod.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("no idle notification after the last instance stopped")
+	}
+	// Повторный Stop — не ошибка.
+	if result, err := m.StopModule(context.Background(), mod); err != nil || result.Outcome != StopNotRunning {
+		t.Errorf("second StopModule = %+v, %v; want %s", result, err, StopNotRunning)
+	}
+}
@@ -0,0 +1,115 @@
+package process
+
+import "time"
+
+const (
+	metricsInterval     = 5 * time.Second
+	metricsHistoryLimit = 120 // 10 минут при интервале 5s
+)
+
+// MetricsSample is the resource usage of a module process and its process group at one moment.
+type MetricsSample struct {
+	Time       time.Time `json:"time"`
+	CPUPercent float64   `json:"cpu_percent"`
+	RSSBytes   uint64    `json:"rss_bytes"`
+	Threads    int       `json:"threads"`
+	OpenFDs    int       `json:"open_fds"`
+	ReadBytes  uint64    `json:"read_bytes"`
+	WriteBytes uint64    `json:"write_bytes"`
+	Processes  int       `json:"processes"`
+}
+
+// ModuleMetrics is the recent metrics history of a module, oldest first.
+type ModuleMetrics struct {
+	Supported bool            `json:"supported"`
+	Latest    *MetricsSample  `json:"latest,omitempty"`
+	History   []MetricsSample `json:"history"`
+}
+
+// groupCounters are the cumulative counters summed over a process group.
+type groupCounters struct {
+	cpuSeconds float64
+	rssBytes   uint64
+	threads    int
+	openFDs    int
+	readBytes  uint64
+	writeBytes uint64
+	processes  int
+}
+
+// Metrics returns the metrics history of a module.
+func (m *Manager) Metrics(moduleID string) ModuleMetrics {
+	metrics := ModuleMetrics{Supported: metricsSupported}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	history := m.metrics[moduleID]
+	metrics.History = append([]MetricsSample{}, history...)
+	if m.processes[moduleID] != nil && len(history) > 0 {
+		latest := history[len(history)-1]
+		metrics.Latest = &latest
+	}
+	return metrics
+}
+
+// LatestMetrics returns the last sample of a running module.
+func (m *Manager) LatestMetrics(moduleID string) (MetricsSample, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	history := m.metrics[moduleID]
+	if m.processes[moduleID] == nil || len(history) == 0 {
+		return MetricsSample{}, false
+	}
+	return history[len(history)-1], true
+}
+
+// metricsLoop samples the process group of pid until the process launched as watch exits.
+func (m *Manager) metricsLoop(proc *moduleProcess, watch *exitWatch, pid int) {
+	if !metricsSupported {
+		return
+	}
+	id := proc.spec.key()
+	prev, err := sampleProcessGroup(pid)
+	if err != nil {
+		return
+	}
+	prevAt := time.Now()
+
+	ticker := time.NewTicker(metricsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-watch.done:
+			return
+		case <-proc.stopCh:
+			return
+		case <-ticker.C:
+		}
+		cur, err := sampleProcessGroup(pid)
+		if err != nil {
+			continue
+		}
+		now := time.Now()
+		sample := MetricsSample{
+			Time:       now,
+			RSSBytes:   cur.rssBytes,
+			Threads:    cur.threads,
+			OpenFDs:    cur.openFDs,
+			ReadBytes:  cur.readBytes,
+			WriteBytes: cur.writeBytes,
+			Processes:  cur.processes,
+		}
+		// Завершившиеся дочерние процессы уменьшают сумму — такой интервал считаем нулевым.
+		if delta := cur.cpuSeconds - prev.cpuSeconds; delta > 0 {
+			sample.CPUPercent = delta / now.Sub(prevAt).Seconds() * 100
+		}
+		prev, prevAt = cur, now
+
+		m.mu.Lock()
+		history := append(m.metrics[id], sample)
+		if len(history) > metricsHistoryLimit {
+			history = history[len(history)-metricsHistoryLimit:]
+		}
+		m.metrics[id] = history
+		m.mu.Unlock()
+	}
+}
@@ -0,0 +1,81 @@
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const metricsSupported = true
+
+// clockTicks is USER_HZ, the unit of utime/stime in /proc/<pid>/stat (100 on all
+// mainstream Linux configurations; reading it needs sysconf).
+const clockTicks = 100
+
+// sampleProcessGroup sums the counters of every process in the process group led by pgid
+// (the module and the helpers it spawned).
+func sampleProcessGroup(pgid int) (groupCounters, error) {
+	var c groupCounters
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return c, err
+	}
+	pageSize := uint64(os.Getpagesize())
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		fields, err := procStatFields(pid)
+		// После ")": 0 state, 2 pgrp, 11 utime, 12 stime, 17 num_threads, 21 rss.
+		if err != nil || len(fields) < 22 || fields[2] != strconv.Itoa(pgid) || fields[0] == "Z" {
+			continue
+		}
+		utime, _ := strconv.ParseUint(fields[11], 10, 64)
+		stime, _ := strconv.ParseUint(fields[12], 10, 64)
+		threads, _ := strconv.Atoi(fields[17])
+		rss, _ := strconv.ParseUint(fields[21], 10, 64)
+
+		c.processes++
+		c.cpuSeconds += float64(utime+stime) / clockTicks
+		c.threads += threads
+		c.rssBytes += rss * pageSize
+		if fds, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", pid)); err == nil {
+			c.openFDs += len(fds)
+		}
+		read, write := procIO(pid)
+		c.readBytes += read
+		c.writeBytes += write
+	}
+	if c.processes == 0 {
+		return c, fmt.Errorf("process group %d not found", pgid)
+	}
+	return c, nil
+}
+
+// procIO returns read_bytes and write_bytes of /proc/<pid>/io (storage I/O).
+func procIO(pid int) (uint64, uint64) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/io", pid))
+	if err != nil {
+		return 0, 0
+	}
+	var read, write uint64
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ": ")
+		if !ok {
+			continue
+		}
+		n, _ := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		switch key {
+		case "read_bytes":
+			read = n
+		case "write_bytes":
+			write = n
+		}
+	}
+	return read, write
+}
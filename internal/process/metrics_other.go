@@ -0,0 +1,12 @@
+//go:build !linux
+
+package process
+
+import "errors"
+
+// metricsSupported is false: process metrics are read from /proc.
+const metricsSupported = false
+
+func sampleProcessGroup(pgid int) (groupCounters, error) {
+	return groupCounters{}, errors.New("process metrics are available on Linux only")
+}
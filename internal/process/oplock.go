@@ -0,0 +1,72 @@
+package process
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+)
+
+// LockModule serializes operations on one module (start, stop, open-ui, install, and the
+// restarts by the supervisor and after failed health checks): it waits until no other
+// operation on moduleID runs and returns the release function. Named instances are locked
+// by their InstanceKey. Shutdown does not take the lock: it cancels the waiting restarts.
+func (m *Manager) LockModule(ctx context.Context, moduleID string) (func(), error) {
+	m.mu.Lock()
+	lock := m.opLocks[moduleID]
+	if lock == nil {
+		lock = make(chan struct{}, 1)
+		m.opLocks[moduleID] = lock
+	}
+	m.mu.Unlock()
+
+	select {
+	case lock <- struct{}{}:
+		return func() { <-lock }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// startExclusive starts one module instance (without dependencies) under its operation lock.
+func (m *Manager) startExclusive(ctx context.Context, mod manifest.ModuleManifest, instance, modulesDir, hubAddr string, showUI, autoConnect bool) error {
+	release, err := m.LockModule(ctx, InstanceKey(mod.ID, instance))
+	if err != nil {
+		return err
+	}
+	defer release()
+	return m.startModule(ctx, mod, instance, modulesDir, hubAddr, showUI, autoConnect)
+}
+
+// RestartModule stops the module and starts it again with new launch flags as one
+// operation, so no other start or stop can slip in between (used by open-ui).
+func (m *Manager) RestartModule(ctx context.Context, mod manifest.ModuleManifest, modulesDir, hubAddr string, showUI, autoConnect bool) error {
+	order, err := m.dependencyOrder(mod)
+	if err != nil {
+		return err
+	}
+	if err := m.startDependencies(ctx, order, modulesDir, hubAddr); err != nil {
+		return err
+	}
+
+	release, err := m.LockModule(ctx, mod.ID)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if _, err := m.stopModule(ctx, mod, "", false); err != nil {
+		return err
+	}
+	return m.startModule(ctx, mod, "", modulesDir, hubAddr, showUI, autoConnect)
+}
+
+// startDependencies starts every module of order except the last one (the dependent itself).
+func (m *Manager) startDependencies(ctx context.Context, order []manifest.ModuleManifest, modulesDir, hubAddr string) error {
+	mod := order[len(order)-1]
+	for _, dep := range order[:len(order)-1] {
+		if err := m.startExclusive(ctx, dep, "", modulesDir, hubAddr, false, true); err != nil {
+			return fmt.Errorf("start dependency %s of %s: %w", dep.ID, mod.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+
+package process
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// configureProcessGroup puts the module into its own process group so that
+// helpers it spawns (e.g. sing-box) are stopped together with it.
+func configureProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	return signalProcessGroup(cmd.Process.Pid, syscall.SIGTERM)
+}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	return signalProcessGroup(cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// processGroupAlive reports whether any process of the group led by pid still exists.
+func processGroupAlive(pid int) bool {
+	err := syscall.Kill(-pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func signalProcessGroup(pid int, sig syscall.Signal) error {
+	if err := syscall.Kill(-pid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+//go:build windows
+
+package process
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// configureProcessGroup starts the module in a new process group; the tree is
+// addressed by taskkill /T when stopping.
+func configureProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// terminateProcessGroup asks the process tree to close (WM_CLOSE) without forcing it.
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	return exec.Command("taskkill", "/T", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	if err := exec.Command("taskkill", "/F", "/T", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
+
+// processGroupAlive is not tracked on Windows: taskkill /T already covers the tree.
+func processGroupAlive(pid int) bool {
+	return false
+}
@@ -0,0 +1,51 @@
+package process
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// processIdentity returns the executable of pid and its start time in clock ticks since
+// boot; together they tell a recorded module process apart from a reused PID.
+func processIdentity(pid int) (string, uint64, error) {
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil {
+		return "", 0, err
+	}
+	fields, err := procStatFields(pid)
+	if err != nil {
+		return "", 0, err
+	}
+	// starttime — поле 22 stat, в fields (начиная с поля 3) это индекс 19.
+	if len(fields) < 20 {
+		return "", 0, fmt.Errorf("short /proc/%d/stat", pid)
+	}
+	start, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("parse /proc/%d/stat: %w", pid, err)
+	}
+	return exe, start, nil
+}
+
+// processAlive reports whether pid exists and has not exited (zombies count as exited).
+func processAlive(pid int) bool {
+	fields, err := procStatFields(pid)
+	return err == nil && len(fields) > 0 && fields[0] != "Z" && fields[0] != "X"
+}
+
+// procStatFields returns the fields of /proc/<pid>/stat after the command name.
+func procStatFields(pid int) ([]string, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
+	// Имя процесса в скобках может содержать пробелы, поэтому режем по последней ")".
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return nil, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	return strings.Fields(string(data[i+1:])), nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux && !windows
+
+package process
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processIdentity is only available on Linux; adoption then relies on the GetInfo probe.
+func processIdentity(pid int) (string, uint64, error) {
+	return "", 0, errIdentityUnsupported
+}
+
+// processAlive reports whether pid exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package process
+
+import "syscall"
+
+// stillActive is the exit code GetExitCodeProcess reports for a running process.
+const stillActive = 259
+
+// processIdentity is only available on Linux; adoption then relies on the GetInfo probe.
+func processIdentity(pid int) (string, uint64, error) {
+	return "", 0, errIdentityUnsupported
+}
+
+// processAlive reports whether pid exists and has not exited.
+func processAlive(pid int) bool {
+	h, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(h)
+	var code uint32
+	return syscall.GetExitCodeProcess(h, &code) == nil && code == stillActive
+}
@@ -0,0 +1,215 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/GalitskyKK/nekkus-core/pkg/protocol"
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	defaultReadyTimeout = 10 * time.Second
+	getInfoTimeout      = 2 * time.Second
+)
+
+// Readiness stages reported in ReadinessError.Stage.
+const (
+	StageListen   = "listen"
+	StageRegister = "register"
+	StageGetInfo  = "get-info"
+)
+
+// ReadinessError reports the stage at which a launched module failed to become ready.
+type ReadinessError struct {
+	ModuleID string
+	Stage    string
+	Err      error
+}
+
+func (e *ReadinessError) Error() string {
+	return fmt.Sprintf("module %s not ready (stage %s): %v", e.ModuleID, e.Stage, e.Err)
+}
+
+func (e *ReadinessError) Unwrap() error {
+	return e.Err
+}
+
+// exitWatch owns cmd.Wait so readiness checks and the supervisor can both observe the exit.
+// output is closed once the captured output of the process has been read to the end.
+type exitWatch struct {
+	done   chan struct{}
+	err    error
+	output <-chan struct{}
+}
+
+func watchExit(cmd *exec.Cmd, key string) *exitWatch {
+	w := &exitWatch{done: make(chan struct{})}
+	go func() {
+		w.err = cmd.Wait()
+		// До close(done): следующий запуск этого экземпляра не должен застать удаление.
+		removeModuleCgroup(key)
+		close(w.done)
+	}()
+	return w
+}
+
+func readyTimeout(mod manifest.ModuleManifest) (time.Duration, error) {
+	if mod.ReadyTimeout == "" {
+		return defaultReadyTimeout, nil
+	}
+	d, err := time.ParseDuration(mod.ReadyTimeout)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid ready_timeout %q for %s", mod.ReadyTimeout, mod.ID)
+	}
+	return d, nil
+}
+
+// NotifyRegistered is called by the hub gRPC service when a module calls Register.
+// grpcPort (0 when the module does not report it) tells instances of the module apart;
+// without it the registration counts for every instance of the module.
+func (m *Manager) NotifyRegistered(moduleID string, grpcPort int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for key, addr := range m.addrs {
+		if key != moduleID && !strings.HasPrefix(key, moduleID+instanceSeparator) {
+			continue
+		}
+		_, port, err := net.SplitHostPort(addr)
+		if grpcPort == 0 || (err == nil && port == strconv.Itoa(grpcPort)) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		keys = []string{moduleID}
+	}
+	now := time.Now()
+	for _, key := range keys {
+		m.registeredAt[key] = now
+		if ch, ok := m.registerWaiters[key]; ok {
+			close(ch)
+			delete(m.registerWaiters, key)
+		}
+	}
+}
+
+// registeredSince reports whether the instance key registered after since, and otherwise
+// returns a channel closed on its next registration.
+func (m *Manager) registeredSince(moduleID string, since time.Time) (bool, <-chan struct{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if at, ok := m.registeredAt[moduleID]; ok && !at.Before(since) {
+		return true, nil
+	}
+	ch, ok := m.registerWaiters[moduleID]
+	if !ok {
+		ch = make(chan struct{})
+		m.registerWaiters[moduleID] = ch
+	}
+	return false, ch
+}
+
+// waitReady waits until the launched module listens on its address and registers with
+// the hub under its own ID; without a Register call it falls back to a GetInfo probe.
+func (m *Manager) waitReady(ctx context.Context, spec launchSpec, launchedAt time.Time, watch *exitWatch) error {
+	mod := spec.manifest
+	timeout, err := readyTimeout(mod)
+	if err != nil {
+		timeout = defaultReadyTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	fail := func(stage string, err error) error {
+		return &ReadinessError{ModuleID: mod.ID, Stage: stage, Err: err}
+	}
+	exited := func() error {
+		if watch.err != nil {
+			return fmt.Errorf("process exited before becoming ready: %w", watch.err)
+		}
+		return fmt.Errorf("process exited before becoming ready")
+	}
+
+	if err := waitForTCP(ctx, spec.addr, watch.done); err != nil {
+		if isDone(watch.done) {
+			return fail(StageListen, exited())
+		}
+		return fail(StageListen, err)
+	}
+
+	if spec.autoConnect {
+		ok, registered := m.registeredSince(spec.key(), launchedAt)
+		if !ok {
+			select {
+			case <-registered:
+				ok = true
+			case <-watch.done:
+				return fail(StageRegister, exited())
+			case <-ctx.Done():
+			}
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	// Модуль не вызвал Register (например, запущен с окном без автоподключения):
+	// убеждаемся хотя бы, что по адресу отвечает именно он.
+	var probeErr error
+	for {
+		probeErr = probeModuleID(spec.addr, mod.ID)
+		if probeErr == nil {
+			return nil
+		}
+		if spec.autoConnect || ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-watch.done:
+			return fail(StageGetInfo, exited())
+		case <-ctx.Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+	if spec.autoConnect {
+		return fail(StageRegister, fmt.Errorf("no Register call within %s; GetInfo fallback: %v", timeout, probeErr))
+	}
+	return fail(StageGetInfo, probeErr)
+}
+
+// probeModuleID calls GetInfo and checks that the module at addr reports the expected ID.
+func probeModuleID(addr, moduleID string) error {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), getInfoTimeout)
+	defer cancel()
+	info, err := pb.NewNekkusModuleClient(conn).GetInfo(ctx, &pb.Empty{})
+	if err != nil {
+		return err
+	}
+	if info.GetId() != moduleID {
+		return fmt.Errorf("address is served by %q, not %s", info.GetId(), moduleID)
+	}
+	return nil
+}
+
+func isDone(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
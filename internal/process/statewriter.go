@@ -0,0 +1,95 @@
+package process
+
+import (
+	"log"
+	"path/filepath"
+	"sort"
+)
+
+// Файлы состояния (processes.json и crashes/<id>.json) пишутся не под m.mu: изменения лишь
+// помечают файл, а писатель снимает копию под блокировкой и пишет на диск после неё.
+
+// stateWrite is a snapshot of one state file.
+type stateWrite struct {
+	path  string
+	value interface{}
+}
+
+// saveStateLocked schedules a write of the running module processes to the state file so
+// that a hub started later can adopt them.
+func (m *Manager) saveStateLocked() {
+	if m.stateDir == "" {
+		return
+	}
+	m.stateDirty = true
+	m.kickStateWriterLocked()
+}
+
+// saveCrashesLocked schedules a write of the crash history of a module.
+func (m *Manager) saveCrashesLocked(moduleID string) {
+	if m.stateDir == "" {
+		return
+	}
+	m.crashesDirty[moduleID] = true
+	m.kickStateWriterLocked()
+}
+
+func (m *Manager) kickStateWriterLocked() {
+	select {
+	case m.stateKick <- struct{}{}:
+	default:
+	}
+}
+
+// stateWriter writes scheduled state files until Shutdown, which flushes the rest itself.
+func (m *Manager) stateWriter() {
+	for {
+		select {
+		case <-m.stateKick:
+			m.FlushState()
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// FlushState writes the scheduled state files now. The hub calls it before exiting without
+// stopping its modules (--detach-modules); Shutdown flushes by itself.
+func (m *Manager) FlushState() {
+	// Писатели по очереди: более старая копия не перезапишет более новую.
+	m.stateWriteMu.Lock()
+	defer m.stateWriteMu.Unlock()
+
+	m.mu.Lock()
+	writes := m.takeStateWritesLocked()
+	m.mu.Unlock()
+
+	for _, w := range writes {
+		if err := writeStateFile(w.path, w.value); err != nil {
+			log.Printf("save %s: %v", w.path, err)
+		}
+	}
+}
+
+// takeStateWritesLocked snapshots the files scheduled for writing and clears the schedule.
+func (m *Manager) takeStateWritesLocked() []stateWrite {
+	var writes []stateWrite
+	if m.stateDirty {
+		m.stateDirty = false
+		records := make([]processRecord, 0, len(m.processes))
+		for _, proc := range m.processes {
+			if proc.running && !proc.stopping {
+				records = append(records, proc.record)
+			}
+		}
+		sort.Slice(records, func(i, j int) bool {
+			return InstanceKey(records[i].ModuleID, records[i].Instance) < InstanceKey(records[j].ModuleID, records[j].Instance)
+		})
+		writes = append(writes, stateWrite{path: filepath.Join(m.stateDir, stateFileName), value: records})
+	}
+	for id := range m.crashesDirty {
+		delete(m.crashesDirty, id)
+		writes = append(writes, stateWrite{path: m.crashFile(id), value: append([]CrashReport{}, m.crashes[id]...)})
+	}
+	return writes
+}
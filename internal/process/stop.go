@@ -0,0 +1,234 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+)
+
+const (
+	defaultStopTimeout = 10 * time.Second
+	// killWaitTimeout bounds the wait for the process to be reaped after SIGKILL.
+	killWaitTimeout = 3 * time.Second
+)
+
+// Stop outcomes reported in StopResult.Outcome.
+const (
+	StopClean      = "clean"
+	StopForced     = "forced"
+	StopNotRunning = "not-running"
+)
+
+// StopResult reports how a module was stopped.
+type StopResult struct {
+	Outcome    string    `json:"outcome"`
+	DurationMs int64     `json:"duration_ms"`
+	Exit       *ExitInfo `json:"exit,omitempty"`
+	// RunningDependents lists modules that depend on the stopped one and are still running.
+	RunningDependents []string `json:"running_dependents,omitempty"`
+}
+
+func stopTimeout(mod manifest.ModuleManifest) (time.Duration, error) {
+	if mod.StopTimeout == "" {
+		return defaultStopTimeout, nil
+	}
+	d, err := time.ParseDuration(mod.StopTimeout)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid stop_timeout %q for %s", mod.StopTimeout, mod.ID)
+	}
+	return d, nil
+}
+
+// StopModule stops the module: gRPC disconnect, SIGTERM to its process group,
+// up to stop_timeout for the group to exit, then SIGKILL. The supervisor will not restart it.
+// Operations on a module are serialized (see LockModule); stopping a stopped module reports
+// StopNotRunning. Cancelling ctx cuts the grace period short.
+func (m *Manager) StopModule(ctx context.Context, mod manifest.ModuleManifest) (StopResult, error) {
+	release, err := m.LockModule(ctx, mod.ID)
+	if err != nil {
+		return StopResult{}, err
+	}
+	defer release()
+	result, err := m.stopModule(ctx, mod, "", false)
+	if result.Outcome != StopNotRunning {
+		result.RunningDependents = m.warnRunningDependents(mod)
+	}
+	return result, err
+}
+
+// stopModule is StopModule for one instance whose grace period is also cut short when ctx
+// is done. force allows stopping a module that is still starting (used on hub shutdown).
+func (m *Manager) stopModule(ctx context.Context, mod manifest.ModuleManifest, instance string, force bool) (StopResult, error) {
+	started := time.Now()
+	key := InstanceKey(mod.ID, instance)
+
+	m.mu.Lock()
+	proc := m.processes[key]
+	if proc == nil {
+		// Остановка упавшего модуля просто сбрасывает crashed/crash-looping.
+		var err error
+		if from := m.stateLocked(key); from != StateStopped {
+			err = m.transitionLocked(key, StateStopped)
+		}
+		m.mu.Unlock()
+		return StopResult{Outcome: StopNotRunning}, err
+	}
+	if !proc.stopping {
+		if err := m.transitionLocked(key, StateStopping); err != nil && !force {
+			m.mu.Unlock()
+			return StopResult{}, err
+		}
+		m.setStateLocked(key, StateStopping, "")
+		proc.stopping = true
+		close(proc.stopCh)
+	}
+	running := proc.running
+	cmd := proc.cmd
+	done := proc.done
+	addr := proc.spec.addr
+	m.mu.Unlock()
+
+	if !running {
+		m.mu.Lock()
+		m.forgetLocked(proc)
+		m.setStateLocked(key, StateStopped, "")
+		m.mu.Unlock()
+		return StopResult{Outcome: StopNotRunning}, nil
+	}
+
+	timeout, err := stopTimeout(mod)
+	if err != nil {
+		timeout = defaultStopTimeout
+	}
+
+	_ = tryDisconnectModule(addr)
+
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	result := StopResult{Outcome: StopClean}
+	if err := terminateProcessGroup(cmd); err != nil {
+		log.Printf("module %s: terminate: %v", key, err)
+	}
+	if !waitGroupExited(ctx, cmd.Process.Pid, done, deadline) {
+		result.Outcome = StopForced
+		log.Printf("module %s did not stop within %s, killing process group", key, timeout)
+		if err := killProcessGroup(cmd); err != nil {
+			return result, m.abandonStop(proc, fmt.Errorf("kill %s: %w", key, err))
+		}
+		select {
+		case <-done:
+		case <-time.After(killWaitTimeout):
+			return result, m.abandonStop(proc, fmt.Errorf("module %s did not exit after kill", key))
+		}
+	}
+	// Помощники модуля могли держать cgroup и после выхода самого модуля.
+	removeModuleCgroup(key)
+
+	m.mu.Lock()
+	if proc.exit != nil {
+		exit := *proc.exit
+		result.Exit = &exit
+	}
+	m.forgetLocked(proc)
+	m.setStateLocked(key, StateStopped, "")
+	m.mu.Unlock()
+
+	result.DurationMs = time.Since(started).Milliseconds()
+	return result, nil
+}
+
+// abandonStop leaves proc in stopping with err when its process could not be killed; the stop
+// is finished (see markExited) once the process exits after all, so the module does not stay
+// stopping forever. It returns nil when the process has exited in the meantime.
+func (m *Manager) abandonStop(proc *moduleProcess, err error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !proc.running {
+		m.forgetLocked(proc)
+		m.setStateLocked(proc.spec.key(), StateStopped, "")
+		return nil
+	}
+	proc.abandoned = true
+	m.setStateLocked(proc.spec.key(), StateStopping, err.Error())
+	return err
+}
+
+// waitGroupExited waits for the leader to be reaped and the rest of its process group to go away.
+func waitGroupExited(ctx context.Context, pid int, done <-chan struct{}, deadline time.Time) bool {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+	for processGroupAlive(pid) {
+		if time.Now().After(deadline) || ctx.Err() != nil {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return true
+}
+
+// Shutdown stops every managed module and refuses further starts and restarts. Launches still
+// waiting for their module to get ready are cancelled, which kills the module. Modules are
+// stopped in parallel, each one after the modules that depend on it. Modules still running
+// when ctx is done are killed; the returned error lists the failures.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.closing = true
+	specs := make([]launchSpec, 0, len(m.processes))
+	stopped := make(map[string]chan struct{}, len(m.processes))
+	for key, proc := range m.processes {
+		specs = append(specs, proc.spec)
+		stopped[key] = make(chan struct{})
+	}
+	m.mu.Unlock()
+	m.cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, spec := range specs {
+		wg.Add(1)
+		go func(spec launchSpec) {
+			defer wg.Done()
+			defer close(stopped[spec.key()])
+			waitForDependents(ctx, spec.manifest.ID, specs, stopped)
+			result, err := m.stopModule(ctx, spec.manifest, spec.instance, true)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", spec.key(), err))
+				mu.Unlock()
+				return
+			}
+			log.Printf("module %s stopped (%s, %dms)", spec.key(), result.Outcome, result.DurationMs)
+		}(spec)
+	}
+	wg.Wait()
+
+	launched := make(chan struct{})
+	go func() {
+		m.launches.Wait()
+		close(launched)
+	}()
+	select {
+	case <-launched:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("cancelled launches: %w", ctx.Err()))
+	}
+	m.FlushState()
+	return errors.Join(errs...)
+}
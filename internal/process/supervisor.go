@@ -0,0 +1,435 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+)
+
+// Supervision defaults used when neither the manifest nor an API override set a field.
+const (
+	defaultRestartMode    = manifest.RestartOnFailure
+	defaultMaxRestarts    = 5
+	defaultRestartWindow  = time.Minute
+	defaultBackoffInitial = time.Second
+	defaultBackoffMax     = 30 * time.Second
+)
+
+// ExitInfo describes how a module process terminated. Unknown is set for an adopted process,
+// whose exit status the hub cannot collect; such an exit is not treated as a failure.
+type ExitInfo struct {
+	Code     int       `json:"code"`
+	Signal   string    `json:"signal,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Unknown  bool      `json:"unknown,omitempty"`
+	ExitedAt time.Time `json:"exited_at"`
+}
+
+// String formats the exit for logs.
+func (e ExitInfo) String() string {
+	switch {
+	case e.Unknown:
+		return "exited (exit status unknown)"
+	case e.Error != "":
+		return e.Error
+	case e.Signal != "":
+		return "signal " + e.Signal
+	default:
+		return fmt.Sprintf("exit code %d", e.Code)
+	}
+}
+
+// failed reports an exit known to be a failure; an unknown exit status is not one.
+func (e ExitInfo) failed() bool {
+	return !e.Unknown && (e.Code != 0 || e.Signal != "" || e.Error != "")
+}
+
+// SupervisorStatus is a snapshot of the supervision state of a module.
+type SupervisorStatus struct {
+	Policy       manifest.RestartPolicy `json:"policy"`
+	Overridden   bool                   `json:"overridden"`
+	Restarts     int                    `json:"restarts"`
+	CrashLooping bool                   `json:"crash_looping"`
+	LastExit     *ExitInfo              `json:"last_exit,omitempty"`
+}
+
+// supervisorState survives process exits so crash-loop detection spans restarts.
+type supervisorState struct {
+	recentRestarts []time.Time
+	totalRestarts  int
+	crashLooping   bool
+	lastExit       *ExitInfo
+}
+
+type restartPolicy struct {
+	mode           string
+	maxRestarts    int
+	window         time.Duration
+	backoffInitial time.Duration
+	backoffMax     time.Duration
+}
+
+// ValidateRestartPolicy checks a restart policy coming from a manifest or the API.
+func ValidateRestartPolicy(p manifest.RestartPolicy) error {
+	_, err := parseRestartPolicy(&p)
+	return err
+}
+
+func parseRestartPolicy(p *manifest.RestartPolicy) (restartPolicy, error) {
+	rp := restartPolicy{
+		mode:           defaultRestartMode,
+		maxRestarts:    defaultMaxRestarts,
+		window:         defaultRestartWindow,
+		backoffInitial: defaultBackoffInitial,
+		backoffMax:     defaultBackoffMax,
+	}
+	if p == nil {
+		return rp, nil
+	}
+	switch p.Mode {
+	case "":
+	case manifest.RestartNever, manifest.RestartOnFailure, manifest.RestartAlways:
+		rp.mode = p.Mode
+	default:
+		return rp, fmt.Errorf("unknown restart mode %q", p.Mode)
+	}
+	if p.MaxRestarts < 0 {
+		return rp, fmt.Errorf("max_restarts must not be negative")
+	}
+	if p.MaxRestarts > 0 {
+		rp.maxRestarts = p.MaxRestarts
+	}
+	fields := []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"window", p.Window, &rp.window},
+		{"backoff_initial", p.BackoffInitial, &rp.backoffInitial},
+		{"backoff_max", p.BackoffMax, &rp.backoffMax},
+	}
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f.value)
+		if err != nil || d <= 0 {
+			return rp, fmt.Errorf("invalid %s %q", f.name, f.value)
+		}
+		*f.dst = d
+	}
+	if rp.backoffMax < rp.backoffInitial {
+		rp.backoffMax = rp.backoffInitial
+	}
+	return rp, nil
+}
+
+func (p restartPolicy) manifest() manifest.RestartPolicy {
+	return manifest.RestartPolicy{
+		Mode:           p.mode,
+		MaxRestarts:    p.maxRestarts,
+		Window:         p.window.String(),
+		BackoffInitial: p.backoffInitial.String(),
+		BackoffMax:     p.backoffMax.String(),
+	}
+}
+
+func (p restartPolicy) shouldRestart(exit ExitInfo) bool {
+	switch p.mode {
+	case manifest.RestartAlways:
+		return true
+	case manifest.RestartOnFailure:
+		return exit.failed()
+	default:
+		return false
+	}
+}
+
+// backoff returns the delay before the n-th restart within the current window (n starts at 0).
+func (p restartPolicy) backoff(n int) time.Duration {
+	delay := p.backoffInitial
+	for i := 0; i < n && delay < p.backoffMax; i++ {
+		delay *= 2
+	}
+	if delay > p.backoffMax {
+		delay = p.backoffMax
+	}
+	return delay
+}
+
+func exitInfoFromWait(state *os.ProcessState, waitErr error) ExitInfo {
+	info := ExitInfo{Code: -1, ExitedAt: time.Now()}
+	if errors.Is(waitErr, errAdoptedExit) {
+		info.Unknown = true
+		return info
+	}
+	if state != nil {
+		info.Code = state.ExitCode()
+		if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			info.Signal = ws.Signal().String()
+		}
+	}
+	var exitErr *exec.ExitError
+	if waitErr != nil && !errors.As(waitErr, &exitErr) {
+		info.Error = waitErr.Error()
+	}
+	return info
+}
+
+// SetRestartPolicy overrides the manifest restart policy of a module (the API also stores it
+// in settings, restored on launch). The override applies to the whole module: the default
+// instance and every named one.
+func (m *Manager) SetRestartPolicy(moduleID string, policy manifest.RestartPolicy) error {
+	if err := ValidateRestartPolicy(policy); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.policies[moduleID] = policy
+	m.mu.Unlock()
+	return nil
+}
+
+// ClearRestartPolicy drops an API override so the manifest policy applies again.
+func (m *Manager) ClearRestartPolicy(moduleID string) {
+	m.mu.Lock()
+	delete(m.policies, moduleID)
+	m.mu.Unlock()
+}
+
+// SupervisorStatus returns the effective restart policy of a module and the crash history of
+// one of its instances (instance "" is the default one).
+func (m *Manager) SupervisorStatus(mod manifest.ModuleManifest, instance string) SupervisorStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	policy, _ := m.restartPolicyLocked(mod)
+	_, overridden := m.policies[mod.ID]
+	status := SupervisorStatus{Policy: policy.manifest(), Overridden: overridden}
+	if state := m.supervision[InstanceKey(mod.ID, instance)]; state != nil {
+		status.Restarts = state.totalRestarts
+		status.CrashLooping = state.crashLooping
+		if state.lastExit != nil {
+			exit := *state.lastExit
+			status.LastExit = &exit
+		}
+	}
+	return status
+}
+
+func (m *Manager) restartPolicyLocked(mod manifest.ModuleManifest) (restartPolicy, error) {
+	if override, ok := m.policies[mod.ID]; ok {
+		return parseRestartPolicy(&override)
+	}
+	return parseRestartPolicy(mod.Restart)
+}
+
+func (m *Manager) supervisorStateLocked(moduleID string) *supervisorState {
+	state := m.supervision[moduleID]
+	if state == nil {
+		state = &supervisorState{}
+		m.supervision[moduleID] = state
+	}
+	return state
+}
+
+// supervise waits for the module process and restarts it according to its restart policy.
+func (m *Manager) supervise(proc *moduleProcess) {
+	for {
+		<-proc.watch.done
+		<-proc.watch.output
+		waitErr := proc.watch.err
+		exit := exitInfoFromWait(proc.cmd.ProcessState, waitErr)
+		m.markExited(proc, exit)
+		for {
+			delay, ok := m.scheduleRestart(proc, exit)
+			if !ok {
+				return
+			}
+			select {
+			case <-time.After(delay):
+			case <-proc.stopCh:
+				m.forget(proc)
+				return
+			}
+			supervised, err := m.relaunch(proc)
+			if !supervised {
+				return
+			}
+			if err == nil {
+				break
+			}
+			exit = ExitInfo{Code: -1, Error: err.Error(), ExitedAt: time.Now()}
+			m.setState(proc.spec.key(), StateCrashed, err.Error())
+		}
+	}
+}
+
+// scheduleRestart records the exit and returns the backoff before the next launch,
+// or false when the module must stay stopped.
+func (m *Manager) scheduleRestart(proc *moduleProcess, exit ExitInfo) (time.Duration, bool) {
+	id := proc.spec.key()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state := m.supervisorStateLocked(id)
+	state.lastExit = &exit
+
+	if proc.stopping || m.closing || m.processes[id] != proc {
+		m.forgetLocked(proc)
+		return 0, false
+	}
+
+	policy, err := m.restartPolicyLocked(proc.spec.manifest)
+	if err != nil || !policy.shouldRestart(exit) {
+		log.Printf("module %s exited: %s", id, exit)
+		m.forgetLocked(proc)
+		return 0, false
+	}
+
+	now := time.Now()
+	recent := state.recentRestarts[:0]
+	for _, t := range state.recentRestarts {
+		if now.Sub(t) < policy.window {
+			recent = append(recent, t)
+		}
+	}
+	state.recentRestarts = recent
+	if len(recent) >= policy.maxRestarts {
+		state.crashLooping = true
+		m.setStateLocked(id, StateCrashLooping, exit.String())
+		log.Printf("module %s is crash-looping (%d restarts within %s), leaving it stopped: %s", id, len(recent), policy.window, exit)
+		m.forgetLocked(proc)
+		return 0, false
+	}
+
+	delay := policy.backoff(len(recent))
+	state.recentRestarts = append(state.recentRestarts, now)
+	state.totalRestarts++
+	log.Printf("module %s exited: %s; restarting in %s", id, exit, delay)
+	return delay, true
+}
+
+// attach installs a relaunched command into proc unless a stop arrived meanwhile.
+func (m *Manager) attach(proc *moduleProcess, cmd *exec.Cmd, watch *exitWatch) bool {
+	m.mu.Lock()
+	if proc.stopping {
+		m.forgetLocked(proc)
+		m.mu.Unlock()
+		_ = killProcessGroup(cmd)
+		<-watch.done
+		return false
+	}
+	proc.cmd = cmd
+	proc.watch = watch
+	proc.record = newProcessRecord(proc.spec, cmd)
+	proc.running = true
+	proc.done = make(chan struct{})
+	proc.exit = nil
+	m.setStateLocked(proc.spec.key(), StateReady, "")
+	m.saveStateLocked()
+	m.mu.Unlock()
+	return true
+}
+
+// relaunch starts a new process for proc under its operation lock, so a restart does not
+// interleave with Start or Stop of the module, and with a context that Shutdown cancels.
+// It returns false when proc is no longer supervised: it was stopped meanwhile or the hub
+// is shutting down.
+func (m *Manager) relaunch(proc *moduleProcess) (bool, error) {
+	release, err := m.LockModule(m.ctx, proc.spec.key())
+	if err != nil {
+		m.forget(proc)
+		return false, nil
+	}
+	defer release()
+	ctx, endLaunch, ok := m.beginRestart(proc)
+	if !ok {
+		return false, nil
+	}
+	defer endLaunch()
+
+	cmd, watch, err := m.launch(ctx, proc.spec)
+	if err != nil {
+		return true, err
+	}
+	if !m.attach(proc, cmd, watch) {
+		return false, nil
+	}
+	go m.healthLoop(proc, watch)
+	go m.metricsLoop(proc, watch, cmd.Process.Pid)
+	return true, nil
+}
+
+// beginRestart moves a supervised module to starting unless it is being stopped and
+// registers the launch (see beginLaunchLocked).
+func (m *Manager) beginRestart(proc *moduleProcess) (context.Context, func(), bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if proc.stopping || m.closing {
+		m.forgetLocked(proc)
+		return nil, nil, false
+	}
+	m.setStateLocked(proc.spec.key(), StateStarting, "")
+	ctx, endLaunch := m.beginLaunchLocked(m.ctx)
+	return ctx, endLaunch, true
+}
+
+// markExited publishes the exit of the current cmd to StopModule.
+func (m *Manager) markExited(proc *moduleProcess, exit ExitInfo) {
+	m.mu.Lock()
+	proc.running = false
+	proc.exit = &exit
+	close(proc.done)
+	// При остановке итоговое состояние выставляет StopModule, если только он не перестал
+	// ждать выхода процесса.
+	if proc.abandoned && m.processes[proc.spec.key()] == proc {
+		m.forgetLocked(proc)
+		m.setStateLocked(proc.spec.key(), StateStopped, "")
+	}
+	if !proc.stopping && m.processes[proc.spec.key()] == proc {
+		m.recordCrashLocked(proc, exit)
+		if exit.failed() {
+			m.setStateLocked(proc.spec.key(), StateCrashed, exit.String())
+		} else {
+			m.setStateLocked(proc.spec.key(), StateStopped, "")
+		}
+	}
+	m.mu.Unlock()
+}
+
+func (m *Manager) forget(proc *moduleProcess) {
+	m.mu.Lock()
+	m.forgetLocked(proc)
+	m.mu.Unlock()
+}
+
+func (m *Manager) forgetLocked(proc *moduleProcess) {
+	id := proc.spec.key()
+	if m.processes[id] == proc {
+		delete(m.processes, id)
+		delete(m.addrs, id)
+		m.saveStateLocked()
+		m.notifyIdleLocked(proc.spec.manifest.ID)
+	}
+}
+
+// notifyIdleLocked runs onIdle if no instance of the module is left.
+func (m *Manager) notifyIdleLocked(moduleID string) {
+	if m.onIdle == nil {
+		return
+	}
+	for _, proc := range m.processes {
+		if proc.spec.manifest.ID == moduleID {
+			return
+		}
+	}
+	// onIdle может обращаться к менеджеру (HasProcesses), а m.mu сейчас захвачен.
+	go m.onIdle(moduleID)
+}
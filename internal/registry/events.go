@@ -0,0 +1,51 @@
+package registry
+
+import "time"
+
+// Event types.
+const (
+	EventAdded   = "added"
+	EventUpdated = "updated"
+	EventRemoved = "removed"
+)
+
+// subscriberBuffer — сколько событий копится у медленного подписчика, прежде чем они теряются.
+const subscriberBuffer = 64
+
+// Event is a change of the discovered modules found by a scan or the modules directory watcher.
+type Event struct {
+	Type     string    `json:"type"`
+	ModuleID string    `json:"module_id"`
+	Version  string    `json:"version,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Subscribe returns a channel of module changes and a function that unsubscribes. A
+// subscriber that does not keep up loses events, so it should re-read the module list.
+func (r *Registry) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	r.mu.Lock()
+	r.subscribers[ch] = struct{}{}
+	r.mu.Unlock()
+	return ch, func() {
+		r.mu.Lock()
+		delete(r.subscribers, ch)
+		r.mu.Unlock()
+	}
+}
+
+func (r *Registry) publish(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for ch := range r.subscribers {
+		for _, event := range events {
+			select {
+			case ch <- event:
+			default:
+			}
+		}
+	}
+}
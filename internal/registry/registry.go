@@ -1,15 +1,22 @@
 package registry
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
 )
 
+// manifestFileName is the manifest of a module in its directory.
+const manifestFileName = "manifest.json"
+
 type registeredEntry struct {
 	ID           string
 	Version      string
@@ -19,52 +26,362 @@ type registeredEntry struct {
 
 // Registry holds discovered module manifests and runtime registrations from modules.
 type Registry struct {
-	mu         sync.RWMutex
-	manifests  map[string]manifest.ModuleManifest
-	registered map[string]registeredEntry
+	mu        sync.RWMutex
+	manifests map[string]manifest.ModuleManifest
+	// loaded — разобранные манифесты по имени каталога модуля, включая отклонённые по зависимостям.
+	loaded      map[string]manifest.ModuleManifest
+	registered  map[string]registeredEntry
+	subscribers map[chan Event]struct{}
+	inUse       func(moduleID string) bool
+	// kept — модули, оставленные последним применением только потому, что они работают.
+	kept map[string]bool
+
+	// Отчёт последнего скана: проблемы и предупреждения загрузки по каталогам и проблемы
+	// проверки всего набора.
+	modulesDir       string
+	scannedAt        time.Time
+	diagnostics      map[string]Diagnostic
+	warnings         map[string][]Diagnostic
+	checkDiagnostics []Diagnostic
+}
+
+// ScanResult is the difference between the modules before and after a scan, as sorted module
+// IDs. Kept modules should have been removed (their directory is gone or they were rejected)
+// but are running, so they stay registered with their previous manifest until they stop
+// (see Release). Rejected maps modules failing the dependency check to the reason.
+type ScanResult struct {
+	Added     []string          `json:"added"`
+	Updated   []string          `json:"updated"`
+	Removed   []string          `json:"removed"`
+	Unchanged []string          `json:"unchanged"`
+	Kept      []string          `json:"kept,omitempty"`
+	Rejected  map[string]string `json:"rejected,omitempty"`
 }
 
 // New creates a new Registry.
 func New() *Registry {
 	return &Registry{
-		manifests:  make(map[string]manifest.ModuleManifest),
-		registered: make(map[string]registeredEntry),
+		manifests:   make(map[string]manifest.ModuleManifest),
+		loaded:      make(map[string]manifest.ModuleManifest),
+		registered:  make(map[string]registeredEntry),
+		subscribers: make(map[chan Event]struct{}),
+		diagnostics: make(map[string]Diagnostic),
+		warnings:    make(map[string][]Diagnostic),
 	}
 }
 
-// ScanModules discovers manifest.json in each subdirectory of modulesDir and updates manifests.
-func (r *Registry) ScanModules(modulesDir string) error {
+// SetInUse sets the check of whether a module is running; a scan does not drop such modules.
+// inUse is called with the registry locked, so it must not call back into the registry.
+func (r *Registry) SetInUse(inUse func(moduleID string) bool) {
+	r.mu.Lock()
+	r.inUse = inUse
+	r.mu.Unlock()
+}
+
+// ScanModules discovers manifest.json in each subdirectory of modulesDir and replaces the
+// manifests with what it found: modules whose directory disappeared are removed unless they
+// are running. Modules with missing or cyclic dependencies are rejected; the returned error
+// lists them.
+func (r *Registry) ScanModules(modulesDir string) (ScanResult, error) {
 	entries, err := os.ReadDir(modulesDir)
 	if err != nil {
-		return err
+		return ScanResult{}, err
 	}
 
+	scanned := make(map[string]manifest.ModuleManifest)
+	diagnostics := make(map[string]Diagnostic)
+	warnings := make(map[string][]Diagnostic)
 	for _, entry := range entries {
 		if !entry.IsDir() {
 			continue
 		}
+		m, diag, warns := loadModuleDir(filepath.Join(modulesDir, entry.Name()))
+		if len(warns) > 0 {
+			warnings[entry.Name()] = warns
+		}
+		if diag != nil {
+			diagnostics[entry.Name()] = *diag
+		} else if m.ID != "" {
+			scanned[entry.Name()] = m
+		}
+	}
 
-		manifestPath := filepath.Join(modulesDir, entry.Name(), "manifest.json")
-		data, readErr := os.ReadFile(manifestPath)
-		if readErr != nil {
-			continue
+	r.mu.Lock()
+	r.loaded = scanned
+	r.diagnostics = diagnostics
+	r.warnings = warnings
+	r.modulesDir = modulesDir
+	result, rejected, events := r.applyLocked()
+	r.mu.Unlock()
+
+	r.publish(events)
+	return result, rejectedError(rejected)
+}
+
+// reloadDirs re-reads the given subdirectories of modulesDir (all of them and every known
+// directory for nil); the module of a directory that no longer has a valid manifest is dropped.
+func (r *Registry) reloadDirs(modulesDir string, dirs []string) (ScanResult, error) {
+	if dirs == nil {
+		if entries, err := os.ReadDir(modulesDir); err == nil {
+			for _, entry := range entries {
+				if entry.IsDir() {
+					dirs = append(dirs, entry.Name())
+				}
+			}
 		}
+		r.mu.RLock()
+		for dir := range r.loaded {
+			dirs = append(dirs, dir)
+		}
+		r.mu.RUnlock()
+	}
 
-		var m manifest.ModuleManifest
-		if unmarshalErr := json.Unmarshal(data, &m); unmarshalErr != nil {
-			continue
+	reloaded := make(map[string]manifest.ModuleManifest)
+	diagnostics := make(map[string]Diagnostic)
+	warnings := make(map[string][]Diagnostic)
+	for _, dir := range dirs {
+		m, diag, warns := loadModuleDir(filepath.Join(modulesDir, dir))
+		if len(warns) > 0 {
+			warnings[dir] = warns
+		}
+		if diag != nil {
+			diagnostics[dir] = *diag
+		} else if m.ID != "" {
+			reloaded[dir] = m
+		}
+	}
+
+	r.mu.Lock()
+	for _, dir := range dirs {
+		if m, ok := reloaded[dir]; ok {
+			r.loaded[dir] = m
+		} else {
+			delete(r.loaded, dir)
+		}
+		if diag, ok := diagnostics[dir]; ok {
+			r.diagnostics[dir] = diag
+		} else {
+			delete(r.diagnostics, dir)
+		}
+		if warns, ok := warnings[dir]; ok {
+			r.warnings[dir] = warns
+		} else {
+			delete(r.warnings, dir)
+		}
+	}
+	r.modulesDir = modulesDir
+	result, rejected, events := r.applyLocked()
+	r.mu.Unlock()
+
+	r.publish(events)
+	return result, rejectedError(rejected)
+}
+
+// loadModuleDir reads manifest.json of a module directory (upgrading an older format) with its
+// local override applied and validates it for the current platform. It returns a diagnostic
+// if the directory has no usable manifest, and neither a manifest nor a diagnostic if the
+// directory does not exist; warnings describe a format upgrade.
+func loadModuleDir(moduleDir string) (manifest.ModuleManifest, *Diagnostic, []Diagnostic) {
+	var m manifest.ModuleManifest
+	path := filepath.Join(moduleDir, manifestFileName)
+	diag := &Diagnostic{Dir: filepath.Base(moduleDir), Path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if _, statErr := os.Stat(moduleDir); os.IsNotExist(statErr) {
+			return m, nil, nil
+		}
+		diag.Kind, diag.Message = DiagnosticUnreadable, err.Error()
+		if os.IsNotExist(err) {
+			diag.Kind, diag.Message = DiagnosticMissingManifest, "no "+manifestFileName+" in the module directory"
+		}
+		return m, diag, nil
+	}
+	m, migrationWarnings, err := manifest.Decode(data)
+	var newer *manifest.NewerVersionError
+	switch {
+	case errors.As(err, &newer):
+		diag.Kind, diag.Field, diag.Message = DiagnosticUnsupportedVersion, "manifest_version", err.Error()
+		return manifest.ModuleManifest{}, diag, nil
+	case err != nil:
+		diag = jsonDiagnostic(path, data, err)
+		diag.ModuleID = m.ID
+		return manifest.ModuleManifest{}, diag, nil
+	}
+	if m.ID == "" {
+		diag.Kind, diag.Field, diag.Message = DiagnosticMissingID, "id", "manifest has no id"
+		return m, diag, nil
+	}
+	var warnings []Diagnostic
+	for _, w := range migrationWarnings {
+		warnings = append(warnings, Diagnostic{
+			Dir:      diag.Dir,
+			Path:     path,
+			Kind:     DiagnosticMigrated,
+			ModuleID: m.ID,
+			Field:    "manifest_version",
+			Message:  w,
+		})
+	}
+
+	local, hasLocal, err := manifest.LoadLocalOverride(moduleDir)
+	if err != nil {
+		localPath := filepath.Join(moduleDir, manifest.LocalFileName)
+		localData, _ := os.ReadFile(localPath)
+		diag = jsonDiagnostic(localPath, localData, err)
+		diag.Kind, diag.ModuleID = DiagnosticLocalOverride, m.ID
+		return manifest.ModuleManifest{}, diag, warnings
+	}
+	if hasLocal {
+		local.Apply(&m)
+	}
+	if err := m.Validate(runtime.GOOS, runtime.GOARCH); err != nil {
+		diag.Kind, diag.ModuleID, diag.Message = DiagnosticInvalid, m.ID, err.Error()
+		var invalid *manifest.ValidationError
+		if errors.As(err, &invalid) {
+			diag.Field, diag.Fields = invalid.Fields[0].Field, invalid.Fields
 		}
+		return manifest.ModuleManifest{}, diag, warnings
+	}
+	return m, nil, warnings
+}
 
-		if m.ID == "" {
+// applyLocked rebuilds manifests from the loaded directories, dropping modules rejected by
+// the dependency check, and returns the difference, the rejected modules and the events.
+func (r *Registry) applyLocked() (ScanResult, map[string]error, []Event) {
+	dirs := make([]string, 0, len(r.loaded))
+	for dir := range r.loaded {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	mods := make([]manifest.ModuleManifest, 0, len(dirs))
+	dirOf := make(map[string]string, len(dirs))
+	r.checkDiagnostics = nil
+	for _, dir := range dirs {
+		m := r.loaded[dir]
+		// При совпадении ID побеждает первый по имени каталог.
+		if first, dup := dirOf[m.ID]; dup {
+			r.checkDiagnostics = append(r.checkDiagnostics, Diagnostic{
+				Dir:      dir,
+				Path:     filepath.Join(r.modulesDir, dir, manifestFileName),
+				Kind:     DiagnosticDuplicateID,
+				ModuleID: m.ID,
+				Field:    "id",
+				Message:  fmt.Sprintf("module %s is already loaded from %s", m.ID, first),
+			})
 			continue
 		}
+		mods = append(mods, m)
+		dirOf[m.ID] = dir
+	}
+	// Работающий модуль без каталога остаётся со старым манифестом: от него могут зависеть другие.
+	kept := make(map[string]bool)
+	for id, old := range r.manifests {
+		if _, ok := dirOf[id]; !ok && r.inUseLocked(id) {
+			mods = append(mods, old)
+			kept[id] = true
+		}
+	}
 
-		r.mu.Lock()
-		r.manifests[m.ID] = m
+	rejected := manifest.CheckDependencies(mods)
+	for id, err := range rejected {
+		if dir, ok := dirOf[id]; ok {
+			r.checkDiagnostics = append(r.checkDiagnostics, Diagnostic{
+				Dir:      dir,
+				Path:     filepath.Join(r.modulesDir, dir, manifestFileName),
+				Kind:     DiagnosticDependency,
+				ModuleID: id,
+				Field:    "depends_on",
+				Message:  err.Error(),
+			})
+		}
+	}
+	next := make(map[string]manifest.ModuleManifest, len(mods))
+	for _, m := range mods {
+		if _, bad := rejected[m.ID]; !bad {
+			next[m.ID] = m
+		} else if old, ok := r.manifests[m.ID]; ok && r.inUseLocked(m.ID) {
+			next[m.ID] = old
+			kept[m.ID] = true
+		}
+	}
+
+	result := ScanResult{
+		Added:     []string{},
+		Updated:   []string{},
+		Removed:   []string{},
+		Unchanged: []string{},
+	}
+	now := time.Now()
+	var events []Event
+	for id, m := range next {
+		old, ok := r.manifests[id]
+		switch {
+		case kept[id]:
+			result.Kept = append(result.Kept, id)
+		case !ok:
+			result.Added = append(result.Added, id)
+			events = append(events, Event{Type: EventAdded, ModuleID: id, Version: m.Version, Time: now})
+		case !reflect.DeepEqual(old, m):
+			result.Updated = append(result.Updated, id)
+			events = append(events, Event{Type: EventUpdated, ModuleID: id, Version: m.Version, Time: now})
+		default:
+			result.Unchanged = append(result.Unchanged, id)
+		}
+	}
+	for id, old := range r.manifests {
+		if _, ok := next[id]; !ok {
+			result.Removed = append(result.Removed, id)
+			events = append(events, Event{Type: EventRemoved, ModuleID: id, Version: old.Version, Time: now})
+		}
+	}
+	for _, ids := range [][]string{result.Added, result.Updated, result.Removed, result.Unchanged, result.Kept} {
+		sort.Strings(ids)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ModuleID < events[j].ModuleID })
+	if len(rejected) > 0 {
+		result.Rejected = make(map[string]string, len(rejected))
+		for id, err := range rejected {
+			result.Rejected[id] = err.Error()
+		}
+	}
+
+	r.manifests = next
+	r.kept = kept
+	r.scannedAt = now
+	return result, rejected, events
+}
+
+// Release re-applies the loaded directories after a module kept because it was running (see
+// ScanResult.Kept) has stopped, so it is dropped like any other removed or rejected module.
+// It does nothing for other modules.
+func (r *Registry) Release(moduleID string) {
+	r.mu.Lock()
+	if !r.kept[moduleID] {
 		r.mu.Unlock()
+		return
 	}
+	_, _, events := r.applyLocked()
+	r.mu.Unlock()
+
+	r.publish(events)
+}
 
-	return nil
+func (r *Registry) inUseLocked(moduleID string) bool {
+	return r.inUse != nil && r.inUse(moduleID)
+}
+
+// rejectedError joins the errors of rejected modules in module ID order.
+func rejectedError(rejected map[string]error) error {
+	ids := make([]string, 0, len(rejected))
+	for id := range rejected {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	errs := make([]error, 0, len(ids))
+	for _, id := range ids {
+		errs = append(errs, rejected[id])
+	}
+	return errors.Join(errs...)
 }
 
 // RegisterModule records a module registration (called from gRPC HubService).
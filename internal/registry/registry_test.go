@@ -0,0 +1,123 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync/atomic"
+	"testing"
+)
+
+// writeModule writes a valid manifest of moduleID for the current platform into dir/name.
+func writeModule(t *testing.T, dir, name, moduleID, version string) {
+	t.Helper()
+	data := fmt.Sprintf(`{"manifest_version": 1, "id": %q, "version": %q, "grpc_addr": "auto", "executable": {%q: "module"}}`,
+		moduleID, version, runtime.GOOS)
+	if err := os.MkdirAll(filepath.Join(dir, name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name, manifestFileName), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func moduleIDs(r *Registry) map[string]bool {
+	ids := make(map[string]bool)
+	for _, m := range r.ListModules() {
+		ids[m.ID] = true
+	}
+	return ids
+}
+
+func TestScanModulesDiff(t *testing.T) {
+	dir := t.TempDir()
+	writeModule(t, dir, "a", "com.a.app", "1.0.0")
+	writeModule(t, dir, "b", "com.b.app", "1.0.0")
+	writeModule(t, dir, "c", "com.c.app", "1.0.0")
+	r := New()
+	result, err := r.ScanModules(dir)
+	if err != nil {
+		t.Fatalf("ScanModules: %v", err)
+	}
+	if want := []string{"com.a.app", "com.b.app", "com.c.app"}; !reflect.DeepEqual(result.Added, want) {
+		t.Fatalf("added = %q, want %q", result.Added, want)
+	}
+
+	events, unsubscribe := r.Subscribe()
+	defer unsubscribe()
+	if err := os.RemoveAll(filepath.Join(dir, "b")); err != nil {
+		t.Fatal(err)
+	}
+	writeModule(t, dir, "c", "com.c.app", "1.1.0")
+	writeModule(t, dir, "d", "com.d.app", "1.0.0")
+	result, err = r.ScanModules(dir)
+	if err != nil {
+		t.Fatalf("ScanModules: %v", err)
+	}
+	want := ScanResult{
+		Added:     []string{"com.d.app"},
+		Updated:   []string{"com.c.app"},
+		Removed:   []string{"com.b.app"},
+		Unchanged: []string{"com.a.app"},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("ScanModules = %+v, want %+v", result, want)
+	}
+	if ids := moduleIDs(r); ids["com.b.app"] || len(ids) != 3 {
+		t.Errorf("modules = %v, want com.b.app removed", ids)
+	}
+	got := make(map[string]string)
+	for range 3 {
+		e := <-events
+		got[e.ModuleID] = e.Type
+	}
+	wantEvents := map[string]string{"com.b.app": EventRemoved, "com.c.app": EventUpdated, "com.d.app": EventAdded}
+	if !reflect.DeepEqual(got, wantEvents) {
+		t.Errorf("events = %v, want %v", got, wantEvents)
+	}
+}
+
+func TestRunningModuleKeptUntilStopped(t *testing.T) {
+	dir := t.TempDir()
+	writeModule(t, dir, "a", "com.a.app", "1.0.0")
+	r := New()
+	var running atomic.Bool
+	running.Store(true)
+	r.SetInUse(func(moduleID string) bool { return moduleID == "com.a.app" && running.Load() })
+	if _, err := r.ScanModules(dir); err != nil {
+		t.Fatalf("ScanModules: %v", err)
+	}
+
+	if err := os.RemoveAll(filepath.Join(dir, "a")); err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.ScanModules(dir)
+	if err != nil {
+		t.Fatalf("ScanModules: %v", err)
+	}
+	if !reflect.DeepEqual(result.Kept, []string{"com.a.app"}) || len(result.Removed) != 0 {
+		t.Fatalf("ScanModules = %+v, want com.a.app kept", result)
+	}
+	if _, ok := r.GetManifest("com.a.app"); !ok {
+		t.Fatal("running module removed by the scan")
+	}
+
+	// Release до остановки ничего не меняет.
+	r.Release("com.a.app")
+	if _, ok := r.GetManifest("com.a.app"); !ok {
+		t.Fatal("module removed while still running")
+	}
+
+	events, unsubscribe := r.Subscribe()
+	defer unsubscribe()
+	running.Store(false)
+	r.Release("com.a.app")
+	if _, ok := r.GetManifest("com.a.app"); ok {
+		t.Error("stopped module still registered after Release")
+	}
+	if e := <-events; e.Type != EventRemoved || e.ModuleID != "com.a.app" {
+		t.Errorf("event = %+v, want com.a.app removed", e)
+	}
+}
@@ -0,0 +1,117 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+)
+
+// Kinds of scan diagnostics; DiagnosticMigrated is a warning.
+const (
+	DiagnosticMissingManifest    = "missing-manifest"
+	DiagnosticUnreadable         = "unreadable"
+	DiagnosticSyntax             = "syntax"
+	DiagnosticType               = "type"
+	DiagnosticUnknownField       = "unknown-field"
+	DiagnosticMissingID          = "missing-id"
+	DiagnosticLocalOverride      = "local-override"
+	DiagnosticInvalid            = "invalid"
+	DiagnosticUnsupportedVersion = "unsupported-version"
+	DiagnosticDuplicateID        = "duplicate-id"
+	DiagnosticDependency         = "dependency"
+	DiagnosticMigrated           = "migrated"
+)
+
+// Diagnostic is a problem that kept the module of a directory from being registered. Line and
+// Column (1-based) point into Path for JSON errors; Field is the offending manifest field and
+// Fields lists every field that failed validation.
+type Diagnostic struct {
+	Dir      string                `json:"dir"`
+	Path     string                `json:"path"`
+	Kind     string                `json:"kind"`
+	ModuleID string                `json:"module_id,omitempty"`
+	Field    string                `json:"field,omitempty"`
+	Fields   []manifest.FieldError `json:"fields,omitempty"`
+	Line     int                   `json:"line,omitempty"`
+	Column   int                   `json:"column,omitempty"`
+	Message  string                `json:"message"`
+}
+
+// ScanReport describes the latest scan of the modules directory (by ScanModules or the
+// watcher): how many modules are registered, why the others are not, and warnings about
+// registered ones (such as an upgraded manifest format).
+type ScanReport struct {
+	ModulesDir  string       `json:"modules_dir"`
+	Time        time.Time    `json:"time"`
+	Modules     int          `json:"modules"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	Warnings    []Diagnostic `json:"warnings"`
+}
+
+// ScanReport returns the report of the latest scan.
+func (r *Registry) ScanReport() ScanReport {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	report := ScanReport{
+		ModulesDir:  r.modulesDir,
+		Time:        r.scannedAt,
+		Modules:     len(r.manifests),
+		Diagnostics: make([]Diagnostic, 0, len(r.diagnostics)+len(r.checkDiagnostics)),
+	}
+	for _, d := range r.diagnostics {
+		report.Diagnostics = append(report.Diagnostics, d)
+	}
+	report.Diagnostics = append(report.Diagnostics, r.checkDiagnostics...)
+	sort.SliceStable(report.Diagnostics, func(i, j int) bool {
+		return report.Diagnostics[i].Dir < report.Diagnostics[j].Dir
+	})
+	report.Warnings = []Diagnostic{}
+	for _, warnings := range r.warnings {
+		report.Warnings = append(report.Warnings, warnings...)
+	}
+	sort.SliceStable(report.Warnings, func(i, j int) bool {
+		return report.Warnings[i].Dir < report.Warnings[j].Dir
+	})
+	return report
+}
+
+// jsonDiagnostic describes a JSON decoding error of the file at path with the given content,
+// including keys the format does not declare.
+func jsonDiagnostic(path string, data []byte, err error) *Diagnostic {
+	d := &Diagnostic{
+		Dir:     filepath.Base(filepath.Dir(path)),
+		Path:    path,
+		Kind:    DiagnosticSyntax,
+		Message: err.Error(),
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var unknown *manifest.ValidationError
+	switch {
+	case errors.As(err, &syntaxErr):
+		d.Line, d.Column = offsetPosition(data, syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		d.Kind = DiagnosticType
+		d.Field = typeErr.Field
+		d.Line, d.Column = offsetPosition(data, typeErr.Offset)
+	case errors.As(err, &unknown):
+		d.Kind = DiagnosticUnknownField
+		d.Field, d.Fields = unknown.Fields[0].Field, unknown.Fields
+	}
+	return d
+}
+
+// offsetPosition converts the offset of a json error (bytes read before the error) to a line
+// and column.
+func offsetPosition(data []byte, offset int64) (int, int) {
+	end := int(min(max(offset-1, 0), int64(len(data))))
+	prefix := data[:end]
+	line := bytes.Count(prefix, []byte{'\n'}) + 1
+	column := len(prefix) - (bytes.LastIndexByte(prefix, '\n') + 1) + 1
+	return line, column
+}
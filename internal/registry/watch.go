@@ -0,0 +1,141 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+)
+
+const (
+	// watchDebounce — пауза после последнего изменения перед перечитыванием: копирование
+	// модуля в каталог порождает целую серию событий.
+	watchDebounce     = 500 * time.Millisecond
+	watchPollInterval = 2 * time.Second
+)
+
+// resyncAll in the changes channel asks for a re-read of every module directory.
+const resyncAll = ""
+
+var errNotifyUnsupported = errors.New("filesystem notifications are not supported on this platform")
+
+// Watch keeps the registry in sync with modulesDir until ctx is done: module directories
+// whose manifest.json or manifest.local.json changed, and directories that appeared or
+// disappeared, are re-read after a short debounce and the changes are published to
+// subscribers. It uses inotify on Linux and polls elsewhere or when inotify fails.
+func (r *Registry) Watch(ctx context.Context, modulesDir string) {
+	changes := make(chan string, subscriberBuffer)
+	go func() {
+		err := watchNotify(ctx, modulesDir, changes)
+		if ctx.Err() != nil {
+			return
+		}
+		if !errors.Is(err, errNotifyUnsupported) {
+			log.Printf("watch %s: %v; falling back to polling", modulesDir, err)
+		}
+		watchPoll(ctx, modulesDir, changes)
+	}()
+
+	pending := make(map[string]bool)
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case dir := <-changes:
+			pending[dir] = true
+			timer.Reset(watchDebounce)
+		case <-timer.C:
+			var dirs []string
+			if !pending[resyncAll] {
+				for dir := range pending {
+					dirs = append(dirs, dir)
+				}
+				sort.Strings(dirs)
+			}
+			clear(pending)
+			result, err := r.reloadDirs(modulesDir, dirs)
+			if err != nil {
+				log.Printf("module watch: %v", err)
+			}
+			for _, id := range result.Kept {
+				log.Printf("module watch: %s is running, keeping it until it stops", id)
+			}
+		}
+	}
+}
+
+func sendChange(ctx context.Context, changes chan<- string, dir string) {
+	select {
+	case changes <- dir:
+	case <-ctx.Done():
+	}
+}
+
+// manifestStamp is the size and modification time of the manifest files of a directory.
+type manifestStamp struct {
+	manifestSize int64
+	manifestTime time.Time
+	localSize    int64
+	localTime    time.Time
+}
+
+// watchPoll compares the manifest files of every module directory each watchPollInterval
+// and sends the names of directories that changed, appeared or disappeared.
+func watchPoll(ctx context.Context, modulesDir string, changes chan<- string) {
+	prev := pollSnapshot(modulesDir)
+	// Пока наблюдение не работало, каталог мог измениться.
+	sendChange(ctx, changes, resyncAll)
+
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		cur := pollSnapshot(modulesDir)
+		for dir, stamp := range cur {
+			if old, ok := prev[dir]; !ok || old != stamp {
+				sendChange(ctx, changes, dir)
+			}
+		}
+		for dir := range prev {
+			if _, ok := cur[dir]; !ok {
+				sendChange(ctx, changes, dir)
+			}
+		}
+		prev = cur
+	}
+}
+
+func pollSnapshot(modulesDir string) map[string]manifestStamp {
+	snapshot := make(map[string]manifestStamp)
+	entries, err := os.ReadDir(modulesDir)
+	if err != nil {
+		return snapshot
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		var stamp manifestStamp
+		dir := filepath.Join(modulesDir, entry.Name())
+		if info, err := os.Stat(filepath.Join(dir, manifestFileName)); err == nil {
+			stamp.manifestSize, stamp.manifestTime = info.Size(), info.ModTime()
+		}
+		if info, err := os.Stat(filepath.Join(dir, manifest.LocalFileName)); err == nil {
+			stamp.localSize, stamp.localTime = info.Size(), info.ModTime()
+		}
+		snapshot[entry.Name()] = stamp
+	}
+	return snapshot
+}
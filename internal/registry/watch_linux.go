@@ -0,0 +1,109 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+)
+
+const (
+	rootWatchMask   = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+	moduleWatchMask = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ONLYDIR
+)
+
+// watchNotify sends the names of changed module directories of modulesDir using inotify
+// until ctx is done. It watches modulesDir itself and every module directory in it.
+func watchNotify(ctx context.Context, modulesDir string, changes chan<- string) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("inotify: %w", err)
+	}
+	// Неблокирующий fd попадает в netpoller, поэтому Close прерывает Read.
+	file := os.NewFile(uintptr(fd), "inotify")
+	defer file.Close()
+	stop := context.AfterFunc(ctx, func() { file.Close() })
+	defer stop()
+
+	rootWD, err := syscall.InotifyAddWatch(fd, modulesDir, rootWatchMask)
+	if err != nil {
+		return fmt.Errorf("inotify watch %s: %w", modulesDir, err)
+	}
+	dirs := make(map[int32]string) // wd → имя каталога модуля
+	addDir := func(name string) {
+		if wd, err := syscall.InotifyAddWatch(fd, filepath.Join(modulesDir, name), moduleWatchMask); err == nil {
+			dirs[int32(wd)] = name
+		}
+	}
+	removeDir := func(name string) {
+		for wd, dir := range dirs {
+			if dir == name {
+				_, _ = syscall.InotifyRmWatch(fd, uint32(wd))
+				delete(dirs, wd)
+			}
+		}
+	}
+	entries, err := os.ReadDir(modulesDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			addDir(entry.Name())
+		}
+	}
+	// Пока наблюдение не работало, каталог мог измениться.
+	sendChange(ctx, changes, resyncAll)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := file.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("inotify read: %w", err)
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(event.Len)]), "\x00")
+			offset = nameStart + int(event.Len)
+
+			switch {
+			case event.Mask&syscall.IN_Q_OVERFLOW != 0:
+				sendChange(ctx, changes, resyncAll)
+			case event.Wd == int32(rootWD):
+				if event.Mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0 {
+					return fmt.Errorf("%s was removed or moved", modulesDir)
+				}
+				if event.Mask&syscall.IN_ISDIR == 0 {
+					continue
+				}
+				if event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+					addDir(name)
+				} else if event.Mask&syscall.IN_MOVED_FROM != 0 {
+					removeDir(name)
+				}
+				sendChange(ctx, changes, name)
+			default:
+				dir, ok := dirs[event.Wd]
+				if !ok {
+					continue
+				}
+				if event.Mask&syscall.IN_IGNORED != 0 {
+					delete(dirs, event.Wd)
+					continue
+				}
+				if name == manifestFileName || name == manifest.LocalFileName {
+					sendChange(ctx, changes, dir)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package registry
+
+import "context"
+
+func watchNotify(ctx context.Context, modulesDir string, changes chan<- string) error {
+	return errNotifyUnsupported
+}
@@ -0,0 +1,56 @@
+package registry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// nextEvent waits for an event from the watcher.
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(10 * time.Second):
+		t.Fatal("no event from the watcher")
+		return Event{}
+	}
+}
+
+func TestWatchPublishesChanges(t *testing.T) {
+	dir := t.TempDir()
+	r := New()
+	if _, err := r.ScanModules(dir); err != nil {
+		t.Fatalf("ScanModules: %v", err)
+	}
+	events, unsubscribe := r.Subscribe()
+	defer unsubscribe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, dir)
+	// Наблюдение начинается асинхронно; изменения до его начала ловит первый опрос или resync.
+	time.Sleep(100 * time.Millisecond)
+
+	writeModule(t, dir, "a", "com.a.app", "1.0.0")
+	if e := nextEvent(t, events); e.Type != EventAdded || e.ModuleID != "com.a.app" {
+		t.Fatalf("event = %+v, want com.a.app added", e)
+	}
+
+	writeModule(t, dir, "a", "com.a.app", "1.1.0")
+	if e := nextEvent(t, events); e.Type != EventUpdated || e.Version != "1.1.0" {
+		t.Fatalf("event = %+v, want com.a.app updated to 1.1.0", e)
+	}
+
+	if err := os.RemoveAll(filepath.Join(dir, "a")); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e.Type != EventRemoved || e.ModuleID != "com.a.app" {
+		t.Fatalf("event = %+v, want com.a.app removed", e)
+	}
+	if _, ok := r.GetManifest("com.a.app"); ok {
+		t.Error("removed module is still registered")
+	}
+}
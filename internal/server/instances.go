@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+
+	coreserver "github.com/GalitskyKK/nekkus-core/pkg/server"
+	"github.com/GalitskyKK/nekkus-hub/internal/api"
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+	"github.com/GalitskyKK/nekkus-hub/internal/process"
+)
+
+// registerInstanceRoutes регистрирует API именованных экземпляров модулей.
+func registerInstanceRoutes(srv *coreserver.Server, cfg api.ServerConfig) {
+	srv.Mux.HandleFunc("GET /api/modules/{id}/instances", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, api.BuildInstanceSummaries(modManifest, cfg.ProcessManager, cfg.Settings))
+	})
+
+	// Создаёт или обновляет экземпляр; тело — launch overrides экземпляра (может быть пустым).
+	srv.Mux.HandleFunc("POST /api/modules/{id}/instances/{instance}", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
+			return
+		}
+		instance := r.PathValue("instance")
+		var overrides manifest.LaunchOverrides
+		if err := json.NewDecoder(r.Body).Decode(&overrides); err != nil && !errors.Is(err, io.EOF) {
+			api.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid launch overrides: " + err.Error()})
+			return
+		}
+		if err := cfg.ProcessManager.SetInstanceOverrides(modManifest.ID, instance, overrides); err != nil {
+			api.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := cfg.Settings.SetInstance(modManifest.ID, instance, &overrides); err != nil {
+			api.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeInstance(w, cfg, modManifest, instance)
+	})
+
+	srv.Mux.HandleFunc("DELETE /api/modules/{id}/instances/{instance}", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, instance, ok := lookupInstance(w, r, cfg)
+		if !ok {
+			return
+		}
+		key := process.InstanceKey(modManifest.ID, instance)
+		if cfg.ProcessManager.IsManaged(key) {
+			api.WriteJSON(w, http.StatusConflict, map[string]string{"error": "instance is running, stop it first"})
+			return
+		}
+		cfg.ProcessManager.ClearLaunchOverrides(key)
+		if err := cfg.Settings.SetInstance(modManifest.ID, instance, nil); err != nil {
+			api.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, map[string]bool{"ok": true})
+	})
+
+	srv.Mux.HandleFunc("GET /api/modules/{id}/instances/{instance}", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, instance, ok := lookupInstance(w, r, cfg)
+		if !ok {
+			return
+		}
+		writeInstance(w, cfg, modManifest, instance)
+	})
+
+	srv.Mux.HandleFunc("POST /api/modules/{id}/instances/{instance}/start", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, instance, ok := lookupInstance(w, r, cfg)
+		if !ok {
+			return
+		}
+		key := process.InstanceKey(modManifest.ID, instance)
+		if err := cfg.ProcessManager.CheckStart(key); err != nil {
+			api.WriteOperationError(w, err)
+			return
+		}
+		submitJob(w, cfg, jobStart, key, func(ctx context.Context) (interface{}, error) {
+			return nil, cfg.ProcessManager.StartInstance(ctx, modManifest, instance, cfg.ModulesDir, cfg.GRPCAddr, false, true)
+		})
+	})
+
+	srv.Mux.HandleFunc("POST /api/modules/{id}/instances/{instance}/stop", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, instance, ok := lookupInstance(w, r, cfg)
+		if !ok {
+			return
+		}
+		key := process.InstanceKey(modManifest.ID, instance)
+		if err := cfg.ProcessManager.CheckStop(key); err != nil {
+			api.WriteOperationError(w, err)
+			return
+		}
+		submitJob(w, cfg, jobStop, key, func(ctx context.Context) (interface{}, error) {
+			return cfg.ProcessManager.StopInstance(ctx, modManifest, instance)
+		})
+	})
+
+	srv.Mux.HandleFunc("GET /api/modules/{id}/instances/{instance}/restart-policy", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, instance, ok := lookupInstance(w, r, cfg)
+		if !ok {
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, cfg.ProcessManager.SupervisorStatus(modManifest, instance))
+	})
+
+	srv.Mux.HandleFunc("GET /api/modules/{id}/instances/{instance}/limits", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, instance, ok := lookupInstance(w, r, cfg)
+		if !ok {
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, cfg.ProcessManager.LimitsStatus(modManifest, instance))
+	})
+
+	srv.Mux.HandleFunc("GET /api/modules/{id}/instances/{instance}/logs", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, instance, ok := lookupInstance(w, r, cfg)
+		if !ok {
+			return
+		}
+		q, err := api.ParseLogQuery(r)
+		if err != nil {
+			api.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"module_id": modManifest.ID,
+			"instance":  instance,
+			"lines":     cfg.ProcessManager.Logs(process.InstanceKey(modManifest.ID, instance), q),
+		})
+	})
+}
+
+// lookupInstance находит manifest и экземпляр из пути; экземпляр должен быть объявлен
+// (POST /api/modules/{id}/instances/{instance}) или управляться менеджером — запущенный
+// экземпляр с удалённым объявлением всё ещё можно остановить. При неудаче ответ уже записан.
+func lookupInstance(w http.ResponseWriter, r *http.Request, cfg api.ServerConfig) (manifest.ModuleManifest, string, bool) {
+	modManifest, ok := lookupManifest(w, r, cfg)
+	if !ok {
+		return manifest.ModuleManifest{}, "", false
+	}
+	instance := r.PathValue("instance")
+	key := process.InstanceKey(modManifest.ID, instance)
+	if instance == "" || (!slices.Contains(cfg.Settings.ModuleInstances(modManifest.ID), instance) && !cfg.ProcessManager.IsManaged(key)) {
+		api.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "instance not found"})
+		return manifest.ModuleManifest{}, "", false
+	}
+	return modManifest, instance, true
+}
+
+func writeInstance(w http.ResponseWriter, cfg api.ServerConfig, mod manifest.ModuleManifest, instance string) {
+	for _, summary := range api.BuildInstanceSummaries(mod, cfg.ProcessManager, cfg.Settings) {
+		if summary.Instance == instance {
+			api.WriteJSON(w, http.StatusOK, summary)
+			return
+		}
+	}
+	api.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "instance not found"})
+}
@@ -1,11 +1,25 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
 	coreserver "github.com/GalitskyKK/nekkus-core/pkg/server"
 	"github.com/GalitskyKK/nekkus-hub/internal/api"
+	"github.com/GalitskyKK/nekkus-hub/internal/jobs"
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+)
+
+// Виды фоновых операций (jobs.Job.Kind).
+const (
+	jobStart       = "start"
+	jobOpenUI      = "open-ui"
+	jobStop        = "stop"
+	jobStopCascade = "stop-cascade"
+	jobInstall     = "install"
 )
 
 // RegisterRoutes регистрирует Hub API на srv.Mux.
@@ -14,83 +28,440 @@ func RegisterRoutes(srv *coreserver.Server, cfg api.ServerConfig) {
 		api.WriteJSON(w, http.StatusOK, cfg.Registry.ListModules())
 	})
 
+	srv.Mux.HandleFunc("GET /api/modules/events", func(w http.ResponseWriter, r *http.Request) {
+		api.StreamModuleEvents(w, r, cfg.Registry)
+	})
+
 	srv.Mux.HandleFunc("GET /api/summary", func(w http.ResponseWriter, r *http.Request) {
-		summaries := api.BuildModuleSummaries(cfg.Registry.ListModules(), cfg.ProcessManager)
+		summaries := api.BuildModuleSummaries(cfg.Registry.ListModules(), cfg.ProcessManager, cfg.Settings, cfg.ModulesDir)
 		api.WriteJSON(w, http.StatusOK, summaries)
 	})
 
 	srv.Mux.HandleFunc("POST /api/scan", func(w http.ResponseWriter, r *http.Request) {
-		if err := cfg.Registry.ScanModules(cfg.ModulesDir); err != nil {
+		result, err := cfg.Registry.ScanModules(cfg.ModulesDir)
+		// Отклонённые по зависимостям модули — часть результата, а не отказ всего скана.
+		if err != nil && len(result.Rejected) == 0 {
 			api.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
 		}
-		api.WriteJSON(w, http.StatusOK, cfg.Registry.ListModules())
+		api.WriteJSON(w, http.StatusOK, result)
+	})
+
+	srv.Mux.HandleFunc("GET /api/manifest/schema", func(w http.ResponseWriter, r *http.Request) {
+		api.WriteJSON(w, http.StatusOK, manifest.JSONSchema())
+	})
+
+	srv.Mux.HandleFunc("GET /api/scan/report", func(w http.ResponseWriter, r *http.Request) {
+		api.WriteJSON(w, http.StatusOK, cfg.Registry.ScanReport())
 	})
 
 	srv.Mux.HandleFunc("POST /api/modules/add", func(w http.ResponseWriter, r *http.Request) {
-		moduleID, err := api.AddModuleFromMultipart(r, cfg.ModulesDir)
+		upload, err := api.ParseModuleUpload(r)
 		if err != nil {
+			var invalid *manifest.ValidationError
+			if errors.As(err, &invalid) {
+				api.WriteJSON(w, http.StatusBadRequest, map[string]interface{}{"error": err.Error(), "fields": invalid.Fields})
+				return
+			}
 			api.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 			return
 		}
-		if err := cfg.Registry.ScanModules(cfg.ModulesDir); err != nil {
-			log.Printf("rescan after add: %v", err)
+		job, created := cfg.Jobs.Submit(jobInstall, upload.ModuleID, func(ctx context.Context) (interface{}, error) {
+			defer upload.Close()
+			// Не перезаписываем файлы модуля посреди его запуска или остановки.
+			release, err := cfg.ProcessManager.LockModule(ctx, upload.ModuleID)
+			if err != nil {
+				return nil, err
+			}
+			defer release()
+			if err := upload.Install(cfg.ModulesDir); err != nil {
+				return nil, err
+			}
+			if _, err := cfg.Registry.ScanModules(cfg.ModulesDir); err != nil {
+				log.Printf("rescan after add: %v", err)
+			}
+			return nil, nil
+		})
+		if !created {
+			_ = upload.Close()
+			api.WriteJSON(w, http.StatusConflict, map[string]interface{}{"error": "module is already being installed", "job": job})
+			return
 		}
-		api.WriteJSON(w, http.StatusOK, map[string]string{"ok": "true", "module_id": moduleID})
+		api.WriteJSON(w, http.StatusAccepted, map[string]interface{}{"ok": "true", "module_id": upload.ModuleID, "job": job})
 	})
 
 	srv.Mux.HandleFunc("POST /api/modules/{id}/start", func(w http.ResponseWriter, r *http.Request) {
-		moduleID := r.PathValue("id")
-		if moduleID == "" {
-			api.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "invalid module route"})
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
+			return
+		}
+		if err := cfg.ProcessManager.CheckStart(modManifest.ID); err != nil {
+			api.WriteOperationError(w, err)
+			return
+		}
+		submitJob(w, cfg, jobStart, modManifest.ID, func(ctx context.Context) (interface{}, error) {
+			return nil, cfg.ProcessManager.StartModule(ctx, modManifest, cfg.ModulesDir, cfg.GRPCAddr, false, true)
+		})
+	})
+
+	srv.Mux.HandleFunc("POST /api/modules/{id}/open-ui", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
+			return
+		}
+		if err := cfg.ProcessManager.CheckStop(modManifest.ID); err != nil {
+			api.WriteOperationError(w, err)
+			return
+		}
+		submitJob(w, cfg, jobOpenUI, modManifest.ID, func(ctx context.Context) (interface{}, error) {
+			return nil, cfg.ProcessManager.RestartModule(ctx, modManifest, cfg.ModulesDir, cfg.GRPCAddr, true, false)
+		})
+	})
+
+	srv.Mux.HandleFunc("POST /api/modules/{id}/stop", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
+			return
+		}
+		if err := cfg.ProcessManager.CheckStop(modManifest.ID); err != nil {
+			api.WriteOperationError(w, err)
+			return
+		}
+		if r.URL.Query().Get("cascade") == "true" {
+			submitJob(w, cfg, jobStopCascade, modManifest.ID, func(ctx context.Context) (interface{}, error) {
+				return cfg.ProcessManager.StopModuleCascade(ctx, modManifest)
+			})
+			return
+		}
+		submitJob(w, cfg, jobStop, modManifest.ID, func(ctx context.Context) (interface{}, error) {
+			return cfg.ProcessManager.StopModule(ctx, modManifest)
+		})
+	})
+
+	registerInstanceRoutes(srv, cfg)
+
+	srv.Mux.HandleFunc("GET /api/jobs", func(w http.ResponseWriter, r *http.Request) {
+		api.WriteJSON(w, http.StatusOK, cfg.Jobs.List())
+	})
+
+	srv.Mux.HandleFunc("GET /api/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		job, ok := cfg.Jobs.Get(r.PathValue("id"))
+		if !ok {
+			api.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, job)
+	})
+
+	srv.Mux.HandleFunc("POST /api/jobs/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		job, ok := cfg.Jobs.Cancel(r.PathValue("id"))
+		if !ok {
+			api.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
 			return
 		}
-		modManifest, ok := cfg.Registry.GetManifest(moduleID)
+		api.WriteJSON(w, http.StatusOK, job)
+	})
+
+	srv.Mux.HandleFunc("GET /api/modules/{id}/status", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
 		if !ok {
-			api.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "module not found"})
 			return
 		}
-		if err := cfg.ProcessManager.StartModule(modManifest, cfg.ModulesDir, cfg.GRPCAddr, false, true); err != nil {
+		addr, _ := cfg.ProcessManager.Addr(modManifest.ID)
+		api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"module_id":  modManifest.ID,
+			"status":     cfg.ProcessManager.Status(modManifest.ID),
+			"running":    cfg.ProcessManager.IsRunning(modManifest.ID),
+			"addr":       addr,
+			"supervisor": cfg.ProcessManager.SupervisorStatus(modManifest, ""),
+			"health":     cfg.ProcessManager.Health(modManifest.ID),
+		})
+	})
+
+	srv.Mux.HandleFunc("GET /api/modules/{id}/restart-policy", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, cfg.ProcessManager.SupervisorStatus(modManifest, ""))
+	})
+
+	srv.Mux.HandleFunc("POST /api/modules/{id}/restart-policy", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
+			return
+		}
+		var policy manifest.RestartPolicy
+		if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+			api.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid restart policy: " + err.Error()})
+			return
+		}
+		if err := cfg.ProcessManager.SetRestartPolicy(modManifest.ID, policy); err != nil {
 			api.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 			return
 		}
-		api.WriteJSON(w, http.StatusOK, map[string]bool{"ok": true})
+		if err := cfg.Settings.SetRestartPolicy(modManifest.ID, &policy); err != nil {
+			api.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, cfg.ProcessManager.SupervisorStatus(modManifest, ""))
 	})
 
-	srv.Mux.HandleFunc("POST /api/modules/{id}/open-ui", func(w http.ResponseWriter, r *http.Request) {
-		moduleID := r.PathValue("id")
-		if moduleID == "" {
-			api.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "invalid module route"})
+	srv.Mux.HandleFunc("DELETE /api/modules/{id}/restart-policy", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
 			return
 		}
-		modManifest, ok := cfg.Registry.GetManifest(moduleID)
+		cfg.ProcessManager.ClearRestartPolicy(modManifest.ID)
+		if err := cfg.Settings.SetRestartPolicy(modManifest.ID, nil); err != nil {
+			api.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, cfg.ProcessManager.SupervisorStatus(modManifest, ""))
+	})
+
+	srv.Mux.HandleFunc("GET /api/modules/{id}/logs", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
 		if !ok {
-			api.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "module not found"})
 			return
 		}
-		_ = cfg.ProcessManager.StopModule(modManifest)
-		if err := cfg.ProcessManager.StartModule(modManifest, cfg.ModulesDir, cfg.GRPCAddr, true, false); err != nil {
+		q, err := api.ParseLogQuery(r)
+		if err != nil {
 			api.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 			return
 		}
-		api.WriteJSON(w, http.StatusOK, map[string]bool{"ok": true})
+		api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"module_id": modManifest.ID,
+			"lines":     cfg.ProcessManager.Logs(modManifest.ID, q),
+		})
 	})
 
-	srv.Mux.HandleFunc("POST /api/modules/{id}/stop", func(w http.ResponseWriter, r *http.Request) {
-		moduleID := r.PathValue("id")
-		if moduleID == "" {
-			api.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "invalid module route"})
+	srv.Mux.HandleFunc("GET /api/modules/{id}/logs/stream", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
+			return
+		}
+		api.StreamLogs(w, r, cfg.ProcessManager, modManifest.ID)
+	})
+
+	srv.Mux.HandleFunc("POST /api/modules/{id}/autostart", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
+			return
+		}
+		var body struct {
+			Autostart *bool `json:"autostart"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			api.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid body: " + err.Error()})
+			return
+		}
+		if err := cfg.Settings.SetAutostart(modManifest.ID, body.Autostart); err != nil {
+			api.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"ok":        true,
+			"autostart": cfg.Settings.Autostart(modManifest),
+			"override":  cfg.Settings.AutostartOverride(modManifest.ID),
+		})
+	})
+
+	srv.Mux.HandleFunc("GET /api/modules/{id}/launch-overrides", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
+			return
+		}
+		writeLaunchOverrides(w, cfg, modManifest)
+	})
+
+	srv.Mux.HandleFunc("POST /api/modules/{id}/launch-overrides", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
+			return
+		}
+		var overrides manifest.LaunchOverrides
+		if err := json.NewDecoder(r.Body).Decode(&overrides); err != nil {
+			api.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid launch overrides: " + err.Error()})
+			return
+		}
+		if err := cfg.ProcessManager.SetLaunchOverrides(modManifest.ID, overrides); err != nil {
+			api.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := cfg.Settings.SetLaunchOverrides(modManifest.ID, &overrides); err != nil {
+			api.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeLaunchOverrides(w, cfg, modManifest)
+	})
+
+	srv.Mux.HandleFunc("DELETE /api/modules/{id}/launch-overrides", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
+			return
+		}
+		cfg.ProcessManager.ClearLaunchOverrides(modManifest.ID)
+		if err := cfg.Settings.SetLaunchOverrides(modManifest.ID, nil); err != nil {
+			api.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeLaunchOverrides(w, cfg, modManifest)
+	})
+
+	srv.Mux.HandleFunc("GET /api/ports", func(w http.ResponseWriter, r *http.Request) {
+		api.WriteJSON(w, http.StatusOK, cfg.ProcessManager.PortAllocations())
+	})
+
+	srv.Mux.HandleFunc("GET /api/modules/{id}/limits", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, cfg.ProcessManager.LimitsStatus(modManifest, ""))
+	})
+
+	srv.Mux.HandleFunc("POST /api/modules/{id}/limits", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
+			return
+		}
+		var limits manifest.ResourceLimits
+		if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+			api.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid limits: " + err.Error()})
+			return
+		}
+		if err := cfg.ProcessManager.SetResourceLimits(modManifest.ID, limits); err != nil {
+			api.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := cfg.Settings.SetResourceLimits(modManifest.ID, &limits); err != nil {
+			api.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
 		}
-		modManifest, ok := cfg.Registry.GetManifest(moduleID)
+		api.WriteJSON(w, http.StatusOK, cfg.ProcessManager.LimitsStatus(modManifest, ""))
+	})
+
+	srv.Mux.HandleFunc("DELETE /api/modules/{id}/limits", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
 		if !ok {
-			api.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "module not found"})
 			return
 		}
-		if err := cfg.ProcessManager.StopModule(modManifest); err != nil {
+		cfg.ProcessManager.ClearResourceLimits(modManifest.ID)
+		if err := cfg.Settings.SetResourceLimits(modManifest.ID, nil); err != nil {
+			api.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, cfg.ProcessManager.LimitsStatus(modManifest, ""))
+	})
+
+	srv.Mux.HandleFunc("GET /api/modules/{id}/env", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
+			return
+		}
+		report, err := cfg.ProcessManager.DebugEnv(modManifest, cfg.ModulesDir, cfg.GRPCAddr)
+		if err != nil {
 			api.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 			return
 		}
-		api.WriteJSON(w, http.StatusOK, map[string]bool{"ok": true})
+		api.WriteJSON(w, http.StatusOK, report)
+	})
+
+	srv.Mux.HandleFunc("GET /api/modules/{id}/metrics", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
+			return
+		}
+		metrics := cfg.ProcessManager.Metrics(modManifest.ID)
+		api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"module_id": modManifest.ID,
+			"supported": metrics.Supported,
+			"latest":    metrics.Latest,
+			"history":   metrics.History,
+		})
+	})
+
+	srv.Mux.HandleFunc("GET /api/modules/{id}/crashes", func(w http.ResponseWriter, r *http.Request) {
+		modManifest, ok := lookupManifest(w, r, cfg)
+		if !ok {
+			return
+		}
+		api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"module_id": modManifest.ID,
+			"crashes":   cfg.ProcessManager.Crashes(modManifest.ID),
+		})
+	})
+
+	srv.Mux.HandleFunc("GET /api/settings", func(w http.ResponseWriter, r *http.Request) {
+		writeSettings(w, cfg)
+	})
+
+	srv.Mux.HandleFunc("POST /api/settings", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			RestoreSession *bool   `json:"restore_session"`
+			EnvMode        *string `json:"env_mode"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			api.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid body: " + err.Error()})
+			return
+		}
+		if body.EnvMode != nil {
+			if err := cfg.ProcessManager.SetEnvMode(*body.EnvMode); err != nil {
+				api.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			if err := cfg.Settings.SetEnvMode(*body.EnvMode); err != nil {
+				api.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+		if body.RestoreSession != nil {
+			if err := cfg.Settings.SetRestoreSession(*body.RestoreSession); err != nil {
+				api.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+		writeSettings(w, cfg)
 	})
 }
+
+// submitJob запускает операцию над модулем в фоне и отвечает 202 с job для опроса
+// через GET /api/jobs/{id}; повторный запрос той же операции возвращает уже идущую.
+// Недопустимый переход обработчики отклоняют с 409 до постановки задачи.
+func submitJob(w http.ResponseWriter, cfg api.ServerConfig, kind, moduleID string, fn jobs.Func) {
+	job, _ := cfg.Jobs.Submit(kind, moduleID, fn)
+	api.WriteJSON(w, http.StatusAccepted, map[string]interface{}{"ok": true, "job": job})
+}
+
+func writeSettings(w http.ResponseWriter, cfg api.ServerConfig) {
+	api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"restore_session": cfg.Settings.RestoreSession(),
+		"last_session":    cfg.Settings.LastSession(),
+		"env_mode":        cfg.ProcessManager.EnvMode(),
+	})
+}
+
+// writeLaunchOverrides отвечает args/env из manifest и пользовательскими дополнениями;
+// изменения применяются при следующем запуске модуля.
+func writeLaunchOverrides(w http.ResponseWriter, cfg api.ServerConfig, mod manifest.ModuleManifest) {
+	api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"manifest":  map[string]interface{}{"args": mod.Args, "env": mod.Env},
+		"overrides": cfg.ProcessManager.LaunchOverrides(mod.ID),
+	})
+}
+
+// lookupManifest находит manifest по {id} из пути; при неудаче ответ уже записан.
+func lookupManifest(w http.ResponseWriter, r *http.Request, cfg api.ServerConfig) (manifest.ModuleManifest, bool) {
+	moduleID := r.PathValue("id")
+	if moduleID == "" {
+		api.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "invalid module route"})
+		return manifest.ModuleManifest{}, false
+	}
+	modManifest, ok := cfg.Registry.GetManifest(moduleID)
+	if !ok {
+		api.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "module not found"})
+		return manifest.ModuleManifest{}, false
+	}
+	return modManifest, true
+}
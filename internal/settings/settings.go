@@ -0,0 +1,329 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+)
+
+const fileName = "settings.json"
+
+// data is the on-disk shape of settings.json.
+type data struct {
+	RestoreSession bool                                `json:"restore_session"`
+	Autostart      map[string]bool                     `json:"autostart,omitempty"`
+	LastSession    []string                            `json:"last_session,omitempty"`
+	Launch         map[string]manifest.LaunchOverrides `json:"launch,omitempty"`
+	EnvMode        string                              `json:"env_mode,omitempty"`
+	Limits         map[string]manifest.ResourceLimits  `json:"limits,omitempty"`
+	Restart        map[string]manifest.RestartPolicy   `json:"restart,omitempty"`
+	// Instances maps a module ID to its named instances and their launch overrides.
+	Instances map[string]map[string]manifest.LaunchOverrides `json:"instances,omitempty"`
+}
+
+// Store holds user settings of the hub persisted in its data dir.
+type Store struct {
+	mu   sync.RWMutex
+	path string
+	data data
+}
+
+// Load reads settings from dataDir; a missing file yields defaults.
+func Load(dataDir string) (*Store, error) {
+	s := &Store{
+		path: filepath.Join(dataDir, fileName),
+		data: data{Autostart: make(map[string]bool)},
+	}
+	raw, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(raw, &s.data); err != nil {
+		return s, fmt.Errorf("parse %s: %w", s.path, err)
+	}
+	if s.data.Autostart == nil {
+		s.data.Autostart = make(map[string]bool)
+	}
+	return s, nil
+}
+
+// RestoreSession reports whether modules running at the last exit are started again on launch.
+func (s *Store) RestoreSession() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data.RestoreSession
+}
+
+// SetRestoreSession toggles restoring of the previous session.
+func (s *Store) SetRestoreSession(enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.RestoreSession = enabled
+	return s.saveLocked()
+}
+
+// Autostart returns the effective autostart flag: the user override if set, else the manifest default.
+func (s *Store) Autostart(mod manifest.ModuleManifest) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if v, ok := s.data.Autostart[mod.ID]; ok {
+		return v
+	}
+	return mod.Autostart
+}
+
+// AutostartOverride returns the user override for a module, nil when the manifest default applies.
+func (s *Store) AutostartOverride(moduleID string) *bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if v, ok := s.data.Autostart[moduleID]; ok {
+		return &v
+	}
+	return nil
+}
+
+// SetAutostart stores a user override; nil removes it.
+func (s *Store) SetAutostart(moduleID string, enabled *bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if enabled == nil {
+		delete(s.data.Autostart, moduleID)
+	} else {
+		s.data.Autostart[moduleID] = *enabled
+	}
+	return s.saveLocked()
+}
+
+// LastSession returns keys of module instances that were running when the hub last exited:
+// module IDs for default instances and "<module id>@<instance>" for named ones.
+func (s *Store) LastSession() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.data.LastSession...)
+}
+
+// SetLastSession records the module instances running at exit.
+func (s *Store) SetLastSession(keys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.LastSession = append([]string(nil), keys...)
+	sort.Strings(s.data.LastSession)
+	return s.saveLocked()
+}
+
+// LaunchOverrides returns the stored per-module launch overrides.
+func (s *Store) LaunchOverrides() map[string]manifest.LaunchOverrides {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]manifest.LaunchOverrides, len(s.data.Launch))
+	for id, o := range s.data.Launch {
+		out[id] = o
+	}
+	return out
+}
+
+// SetLaunchOverrides stores launch overrides of a module; nil removes them.
+func (s *Store) SetLaunchOverrides(moduleID string, o *manifest.LaunchOverrides) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if o == nil {
+		delete(s.data.Launch, moduleID)
+	} else {
+		if s.data.Launch == nil {
+			s.data.Launch = make(map[string]manifest.LaunchOverrides)
+		}
+		s.data.Launch[moduleID] = *o
+	}
+	return s.saveLocked()
+}
+
+// Instances returns the stored named instances of every module with their launch overrides.
+func (s *Store) Instances() map[string]map[string]manifest.LaunchOverrides {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]map[string]manifest.LaunchOverrides, len(s.data.Instances))
+	for id, instances := range s.data.Instances {
+		out[id] = make(map[string]manifest.LaunchOverrides, len(instances))
+		for name, o := range instances {
+			out[id][name] = o
+		}
+	}
+	return out
+}
+
+// ModuleInstances returns the sorted names of the stored instances of a module.
+func (s *Store) ModuleInstances(moduleID string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.data.Instances[moduleID]))
+	for name := range s.data.Instances[moduleID] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetInstance stores a named instance of a module with its launch overrides; nil removes it.
+func (s *Store) SetInstance(moduleID, name string, o *manifest.LaunchOverrides) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if o == nil {
+		delete(s.data.Instances[moduleID], name)
+		if len(s.data.Instances[moduleID]) == 0 {
+			delete(s.data.Instances, moduleID)
+		}
+	} else {
+		if s.data.Instances == nil {
+			s.data.Instances = make(map[string]map[string]manifest.LaunchOverrides)
+		}
+		if s.data.Instances[moduleID] == nil {
+			s.data.Instances[moduleID] = make(map[string]manifest.LaunchOverrides)
+		}
+		s.data.Instances[moduleID][name] = *o
+	}
+	return s.saveLocked()
+}
+
+// ResourceLimits returns the stored per-module resource limits.
+func (s *Store) ResourceLimits() map[string]manifest.ResourceLimits {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]manifest.ResourceLimits, len(s.data.Limits))
+	for id, l := range s.data.Limits {
+		out[id] = l
+	}
+	return out
+}
+
+// SetResourceLimits stores resource limits of a module; nil removes them.
+func (s *Store) SetResourceLimits(moduleID string, l *manifest.ResourceLimits) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l == nil {
+		delete(s.data.Limits, moduleID)
+	} else {
+		if s.data.Limits == nil {
+			s.data.Limits = make(map[string]manifest.ResourceLimits)
+		}
+		s.data.Limits[moduleID] = *l
+	}
+	return s.saveLocked()
+}
+
+// RestartPolicies returns the stored per-module restart policies.
+func (s *Store) RestartPolicies() map[string]manifest.RestartPolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]manifest.RestartPolicy, len(s.data.Restart))
+	for id, p := range s.data.Restart {
+		out[id] = p
+	}
+	return out
+}
+
+// SetRestartPolicy stores the restart policy of a module; nil removes it.
+func (s *Store) SetRestartPolicy(moduleID string, p *manifest.RestartPolicy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p == nil {
+		delete(s.data.Restart, moduleID)
+	} else {
+		if s.data.Restart == nil {
+			s.data.Restart = make(map[string]manifest.RestartPolicy)
+		}
+		s.data.Restart[moduleID] = *p
+	}
+	return s.saveLocked()
+}
+
+// EnvMode returns how the hub environment is passed to modules; empty means the hub default.
+func (s *Store) EnvMode() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data.EnvMode
+}
+
+// SetEnvMode stores the env mode (validated by the caller).
+func (s *Store) SetEnvMode(mode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.EnvMode = mode
+	return s.saveLocked()
+}
+
+// LaunchSet returns the modules of mods to start on hub launch: autostart ones plus, in
+// restore mode, the last session. Every module comes after the modules it depends on (see
+// manifest.DependencyOrder), otherwise in ID order.
+func (s *Store) LaunchSet(mods []manifest.ModuleManifest) []manifest.ModuleManifest {
+	want := make(map[string]bool)
+	if s.RestoreSession() {
+		for _, id := range s.LastSession() {
+			want[id] = true
+		}
+	}
+	var out []manifest.ModuleManifest
+	for _, mod := range mods {
+		if want[mod.ID] || s.Autostart(mod) {
+			out = append(out, mod)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return dependencyOrdered(out, mods)
+}
+
+// dependencyOrdered reorders selected so that each module follows those of selected it
+// depends on, directly or through other modules of mods. A module whose dependencies cannot
+// be resolved keeps its place; starting it reports the problem.
+func dependencyOrdered(selected, mods []manifest.ModuleManifest) []manifest.ModuleManifest {
+	byID := make(map[string]manifest.ModuleManifest, len(mods))
+	for _, mod := range mods {
+		byID[mod.ID] = mod
+	}
+	lookup := func(id string) (manifest.ModuleManifest, bool) {
+		mod, ok := byID[id]
+		return mod, ok
+	}
+	want := make(map[string]bool, len(selected))
+	for _, mod := range selected {
+		want[mod.ID] = true
+	}
+	seen := make(map[string]bool, len(selected))
+	out := make([]manifest.ModuleManifest, 0, len(selected))
+	for _, mod := range selected {
+		order, err := manifest.DependencyOrder(mod, lookup)
+		if err != nil {
+			order = []manifest.ModuleManifest{mod}
+		}
+		for _, m := range order {
+			if want[m.ID] && !seen[m.ID] {
+				seen[m.ID] = true
+				out = append(out, m)
+			}
+		}
+	}
+	return out
+}
+
+func (s *Store) saveLocked() error {
+	raw, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,97 @@
+package settings
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/GalitskyKK/nekkus-hub/internal/manifest"
+)
+
+func TestStorePersists(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	enabled := false
+	limits := manifest.ResourceLimits{MaxMemory: "512M"}
+	policy := manifest.RestartPolicy{Mode: manifest.RestartNever}
+	overrides := manifest.LaunchOverrides{Args: []string{"--verbose"}}
+	for _, err := range []error{
+		s.SetRestoreSession(true),
+		s.SetAutostart("com.a.app", &enabled),
+		s.SetLastSession([]string{"com.b.app", "com.a.app@second"}),
+		s.SetResourceLimits("com.a.app", &limits),
+		s.SetRestartPolicy("com.a.app", &policy),
+		s.SetLaunchOverrides("com.a.app", &overrides),
+		s.SetInstance("com.a.app", "second", &overrides),
+		s.SetEnvMode("isolated"),
+	} {
+		if err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !loaded.RestoreSession() || loaded.EnvMode() != "isolated" {
+		t.Errorf("restore_session = %v, env_mode = %q", loaded.RestoreSession(), loaded.EnvMode())
+	}
+	if loaded.Autostart(manifest.ModuleManifest{ID: "com.a.app", Autostart: true}) {
+		t.Error("autostart override lost")
+	}
+	if got, want := loaded.LastSession(), []string{"com.a.app@second", "com.b.app"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("last_session = %q, want %q", got, want)
+	}
+	if got := loaded.ResourceLimits()["com.a.app"]; !reflect.DeepEqual(got, limits) {
+		t.Errorf("limits = %+v, want %+v", got, limits)
+	}
+	if got := loaded.RestartPolicies()["com.a.app"]; !reflect.DeepEqual(got, policy) {
+		t.Errorf("restart policy = %+v, want %+v", got, policy)
+	}
+	if got := loaded.LaunchOverrides()["com.a.app"]; !reflect.DeepEqual(got, overrides) {
+		t.Errorf("launch overrides = %+v, want %+v", got, overrides)
+	}
+	if got := loaded.ModuleInstances("com.a.app"); !reflect.DeepEqual(got, []string{"second"}) {
+		t.Errorf("instances = %q, want [second]", got)
+	}
+
+	// nil убирает сохранённое значение.
+	if err := loaded.SetRestartPolicy("com.a.app", nil); err != nil {
+		t.Fatalf("SetRestartPolicy: %v", err)
+	}
+	if reloaded, _ := Load(dir); len(reloaded.RestartPolicies()) != 0 {
+		t.Errorf("restart policies = %+v, want none", reloaded.RestartPolicies())
+	}
+}
+
+func TestLaunchSetDependencyOrder(t *testing.T) {
+	s, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	mods := []manifest.ModuleManifest{
+		{ID: "com.a.app", Autostart: true, DependsOn: []manifest.Dependency{{ID: "com.z.api"}}},
+		{ID: "com.z.api", DependsOn: []manifest.Dependency{{ID: "com.m.db"}}},
+		{ID: "com.m.db", Autostart: true},
+		{ID: "com.b.cycle", Autostart: true, DependsOn: []manifest.Dependency{{ID: "com.b.cycle"}}},
+		{ID: "com.c.idle"},
+	}
+	if err := s.SetRestoreSession(true); err != nil {
+		t.Fatalf("SetRestoreSession: %v", err)
+	}
+	if err := s.SetLastSession([]string{"com.z.api", "com.c.idle@second"}); err != nil {
+		t.Fatalf("SetLastSession: %v", err)
+	}
+
+	var ids []string
+	for _, mod := range s.LaunchSet(mods) {
+		ids = append(ids, mod.ID)
+	}
+	want := []string{"com.m.db", "com.z.api", "com.a.app", "com.b.cycle"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("LaunchSet = %q, want %q", ids, want)
+	}
+}